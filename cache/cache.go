@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultShardNum 默认的分片数量
+const DefaultShardNum = 16
+
+type (
+	// Key 缓存的键，由数据类型、文件id和entry在文件中的偏移唯一确定
+	// 数据文件是追加写入的，同一位置的entry写入后不会再被修改，因此可以安全地缓存
+	Key struct {
+		Type   uint16
+		FileId uint32
+		Offset int64
+	}
+
+	// Cache 分片的LRU缓存，容量按value的字节数计算
+	Cache struct {
+		shards []*shard
+		mask   uint64
+		hits   uint64
+		misses uint64
+	}
+
+	// Stats 缓存的命中统计信息
+	Stats struct {
+		Hits      uint64 // 命中次数
+		Misses    uint64 // 未命中次数
+		Evictions uint64 // 被淘汰的数据个数
+		Entries   int    // 当前缓存的数据个数
+		Size      int64  // 当前缓存占用的字节数
+	}
+
+	shard struct {
+		mu        sync.Mutex
+		capacity  int64
+		size      int64
+		evictions uint64
+		ll        *list.List
+		items     map[Key]*list.Element
+	}
+
+	item struct {
+		key   Key
+		value []byte
+	}
+)
+
+// New 新建一个容量为 capacity 字节的缓存，shardNum 会被调整为2的幂次
+func New(capacity int64, shardNum int) *Cache {
+	if shardNum <= 0 {
+		shardNum = DefaultShardNum
+	}
+	n := 1
+	for n < shardNum {
+		n <<= 1
+	}
+
+	c := &Cache{shards: make([]*shard, n), mask: uint64(n - 1)}
+	for i := range c.shards {
+		c.shards[i] = &shard{
+			capacity: capacity / int64(n),
+			ll:       list.New(),
+			items:    make(map[Key]*list.Element),
+		}
+	}
+	return c
+}
+
+// Get 获取缓存的value，同时将其移动到LRU链表的头部
+func (c *Cache) Get(k Key) ([]byte, bool) {
+	s := c.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[k]; ok {
+		s.ll.MoveToFront(e)
+		atomic.AddUint64(&c.hits, 1)
+		return e.Value.(*item).value, true
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return nil, false
+}
+
+// Put 添加缓存，超出容量时淘汰最久未被使用的数据
+// 大于单个分片容量的value不会被缓存
+func (c *Cache) Put(k Key, value []byte) {
+	s := c.shard(k)
+	size := int64(len(value))
+	if size > s.capacity {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[k]; ok {
+		it := e.Value.(*item)
+		s.size += size - int64(len(it.value))
+		it.value = value
+		s.ll.MoveToFront(e)
+	} else {
+		s.items[k] = s.ll.PushFront(&item{key: k, value: value})
+		s.size += size
+	}
+
+	for s.size > s.capacity {
+		s.removeElement(s.ll.Back())
+		s.evictions++
+	}
+}
+
+// Remove 删除缓存
+func (c *Cache) Remove(k Key) {
+	s := c.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[k]; ok {
+		s.removeElement(e)
+	}
+}
+
+// Purge 清空所有缓存
+func (c *Cache) Purge() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.ll.Init()
+		s.items = make(map[Key]*list.Element)
+		s.size = 0
+		s.mu.Unlock()
+	}
+}
+
+// Stats 返回缓存的统计信息
+func (c *Cache) Stats() (st Stats) {
+	st.Hits = atomic.LoadUint64(&c.hits)
+	st.Misses = atomic.LoadUint64(&c.misses)
+	for _, s := range c.shards {
+		s.mu.Lock()
+		st.Evictions += s.evictions
+		st.Entries += s.ll.Len()
+		st.Size += s.size
+		s.mu.Unlock()
+	}
+	return
+}
+
+func (c *Cache) shard(k Key) *shard {
+	h := uint64(k.Offset)*0x9E3779B97F4A7C15 ^ uint64(k.FileId)<<16 ^ uint64(k.Type)
+	h ^= h >> 29
+	return c.shards[h&c.mask]
+}
+
+func (s *shard) removeElement(e *list.Element) {
+	it := e.Value.(*item)
+	s.ll.Remove(e)
+	delete(s.items, it.key)
+	s.size -= int64(len(it.value))
+}
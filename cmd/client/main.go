@@ -14,7 +14,7 @@ import (
 
 // all supported commands
 var commandList = [][]string{
-	{"SET", "key value", "STRING"},
+	{"SET", "key value [EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp] [NX|XX] [KEEPTTL]", "STRING"},
 	{"GET", "key", "STRING"},
 	{"SETNX", "key value", "STRING"},
 	{"GETSET", "key value", "STRING"},
@@ -24,9 +24,35 @@ var commandList = [][]string{
 	{"STRREM", "key", "STRING"},
 	{"PREFIXSCAN", "prefix limit offset", "STRING"},
 	{"RANGESCAN", "start end", "STRING"},
+	{"RANGE", "start end [REV] [LIMIT n] [WITHKEYS]", "STRING"},
 	{"EXPIRE", "key seconds", "STRING"},
 	{"PERSIST", "key", "STRING"},
 	{"TTL", "key", "STRING"},
+	{"PEXPIRE", "key milliseconds", "STRING"},
+	{"EXPIREAT", "key timestamp", "STRING"},
+	{"PEXPIREAT", "key milliseconds-timestamp", "STRING"},
+	{"PTTL", "key", "STRING"},
+	{"INCR", "key", "STRING"},
+	{"DECR", "key", "STRING"},
+	{"INCRBY", "key increment", "STRING"},
+	{"DECRBY", "key decrement", "STRING"},
+	{"INCRBYFLOAT", "key increment", "STRING"},
+	{"MGET", "key [key...]", "STRING"},
+	{"MSET", "key value [key value...]", "STRING"},
+	{"MSETNX", "key value [key value...]", "STRING"},
+	{"GETRANGE", "key start end", "STRING"},
+	{"SETRANGE", "key offset value", "STRING"},
+	{"GETDEL", "key", "STRING"},
+	{"GETEX", "key [EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp|PERSIST]", "STRING"},
+	{"SETBIT", "key offset value", "STRING"},
+	{"GETBIT", "key offset", "STRING"},
+	{"BITCOUNT", "key [start end [BYTE|BIT]]", "STRING"},
+	{"BITPOS", "key bit [start [end [BYTE|BIT]]]", "STRING"},
+	{"BITOP", "AND|OR|XOR|NOT destkey key [key...]", "STRING"},
+
+	{"PFADD", "key [element...]", "HYPERLOGLOG"},
+	{"PFCOUNT", "key [key...]", "HYPERLOGLOG"},
+	{"PFMERGE", "destkey [sourcekey...]", "HYPERLOGLOG"},
 
 	{"LPUSH", "key value [value...]", "LIST"},
 	{"RPUSH", "key value [value...]", "LIST"},
@@ -39,6 +65,14 @@ var commandList = [][]string{
 	{"LTRIM", "key start end", "LIST"},
 	{"LRANGE", "key start end", "LIST"},
 	{"LLEN", "key", "LIST"},
+	{"LPUSHX", "key value [value...]", "LIST"},
+	{"RPUSHX", "key value [value...]", "LIST"},
+	{"LMOVE", "source destination LEFT|RIGHT LEFT|RIGHT", "LIST"},
+	{"RPOPLPUSH", "source destination", "LIST"},
+	{"LPOS", "key element [RANK rank] [COUNT num-matches] [MAXLEN len]", "LIST"},
+	{"BLPOP", "key [key...] timeout", "LIST"},
+	{"BRPOP", "key [key...] timeout", "LIST"},
+	{"BLMOVE", "source destination LEFT|RIGHT LEFT|RIGHT timeout", "LIST"},
 
 	{"HSET", "key field value", "HASH"},
 	{"HSETNX", "key field value", "HASH"},
@@ -49,6 +83,9 @@ var commandList = [][]string{
 	{"HLEN", "key", "HASH"},
 	{"HKEYS", "key", "HASH"},
 	{"HVALUES", "key", "HASH"},
+	{"HSCAN", "key cursor [MATCH pattern] [COUNT count]", "HASH"},
+	{"HINCRBY", "key field increment", "HASH"},
+	{"HINCRBYFLOAT", "key field increment", "HASH"},
 
 	{"SADD", "key members [members...]", "SET"},
 	{"SPOP", "key count", "SET"},
@@ -60,6 +97,13 @@ var commandList = [][]string{
 	{"SMEMBERS", "key", "SET"},
 	{"SUNION", "key [key...]", "SET"},
 	{"SDIFF", "key [key...]", "SET"},
+	{"SINTER", "key [key...]", "SET"},
+	{"SINTERSTORE", "destination key [key...]", "SET"},
+	{"SUNIONSTORE", "destination key [key...]", "SET"},
+	{"SDIFFSTORE", "destination key [key...]", "SET"},
+	{"SMISMEMBER", "key member [member...]", "SET"},
+	{"SINTERCARD", "numkeys key [key...] [LIMIT limit]", "SET"},
+	{"SSCAN", "key cursor [MATCH pattern] [COUNT count]", "SET"},
 
 	{"ZADD", "key score member", "ZSET"},
 	{"ZSCORE", "key member", "ZSET"},
@@ -74,6 +118,37 @@ var commandList = [][]string{
 	{"ZREVGETBYRANK", "key rank", "ZSET"},
 	{"ZSCORERANGE", "key min max", "ZSET"},
 	{"ZREVSCORERANGE", "key max min", "ZSET"},
+	{"ZSCAN", "key cursor [MATCH pattern] [COUNT count]", "ZSET"},
+
+	{"XADD", "key *|id field value [field value...]", "STREAM"},
+	{"XLEN", "key", "STREAM"},
+	{"XRANGE", "key start end [COUNT count]", "STREAM"},
+	{"XREVRANGE", "key end start [COUNT count]", "STREAM"},
+	{"XREAD", "[COUNT count] STREAMS key [key...] id [id...]", "STREAM"},
+	{"XGROUP", "CREATE key group id|$ [MKSTREAM]", "STREAM"},
+	{"XREADGROUP", "GROUP group consumer [COUNT count] [NOACK] STREAMS key [key...] id [id...]", "STREAM"},
+	{"XACK", "key group id [id...]", "STREAM"},
+	{"XPENDING", "key group [start end count [consumer]]", "STREAM"},
+
+	{"JSON.SET", "key path value [NX|XX]", "JSON"},
+	{"JSON.GET", "key [path...]", "JSON"},
+	{"JSON.DEL", "key [path]", "JSON"},
+	{"JSON.NUMINCRBY", "key path value", "JSON"},
+
+	{"GEOADD", "key longitude latitude member [longitude latitude member...]", "GEO"},
+	{"GEOPOS", "key member [member...]", "GEO"},
+	{"GEODIST", "key member1 member2 [m|km|ft|mi]", "GEO"},
+	{"GEOSEARCH", "key FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius m|km|ft|mi|BYBOX width height m|km|ft|mi [ASC|DESC] [COUNT count] [WITHCOORD] [WITHDIST] [WITHHASH]", "GEO"},
+
+	{"DEL", "key [key...]", "KEY"},
+	{"EXISTS", "key [key...]", "KEY"},
+	{"TYPE", "key", "KEY"},
+	{"KEYS", "pattern", "KEY"},
+	{"RENAME", "key newkey", "KEY"},
+	{"RENAMENX", "key newkey", "KEY"},
+	{"DBSIZE", "", "KEY"},
+	{"FLUSHDB", "", "KEY"},
+	{"SCAN", "cursor [MATCH pattern] [COUNT count]", "KEY"},
 }
 
 var host = flag.String("h", "127.0.0.1", "the mindb server host, default 127.0.0.1")
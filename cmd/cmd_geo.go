@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"KV_Storage"
+	"strconv"
+	"strings"
+)
+
+func geoAdd(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 4 || (len(args)-1)%3 != 0 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var locations []KV_Storage.GeoLocation
+	for i := 1; i < len(args); i += 3 {
+		var loc KV_Storage.GeoLocation
+		if loc.Longitude, loc.Latitude, err = parseLonLat(args[i], args[i+1]); err != nil {
+			return
+		}
+		loc.Member = []byte(args[i+2])
+		locations = append(locations, loc)
+	}
+
+	var added int
+	if added, err = db.GeoAdd([]byte(args[0]), locations...); err == nil {
+		res = strconv.Itoa(added)
+	}
+	return
+}
+
+func geoPos(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var members [][]byte
+	for _, m := range args[1:] {
+		members = append(members, []byte(m))
+	}
+	val := db.GeoPos([]byte(args[0]), members...)
+	for i, loc := range val {
+		if loc == nil {
+			res += "<nil>"
+		} else {
+			res += formatLonLat(loc.Longitude, loc.Latitude)
+		}
+		if i != len(val)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+func geoDist(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 3 && len(args) != 4 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var unit string
+	if len(args) == 4 {
+		unit = args[3]
+	}
+	dist, ok, err := db.GeoDist([]byte(args[0]), []byte(args[1]), []byte(args[2]), unit)
+	if err != nil {
+		return
+	}
+	if ok {
+		res = strconv.FormatFloat(dist, 'f', 4, 64)
+	} else {
+		res = "<nil>"
+	}
+	return
+}
+
+// GEOSEARCH key FROMMEMBER member|FROMLONLAT longitude latitude BYRADIUS radius unit|BYBOX width height unit
+// [ASC|DESC] [COUNT count] [WITHCOORD] [WITHDIST] [WITHHASH]
+// 每个结果占一行，依次为成员、距离、geohash 和经纬度
+func geoSearch(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var opts KV_Storage.GeoSearchOptions
+	var withCoord, withDist, withHash, hasFrom, hasBy bool
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "FROMMEMBER":
+			if hasFrom || i+1 >= len(args) {
+				return "", ErrSyntaxIncorrect
+			}
+			opts.Member = []byte(args[i+1])
+			hasFrom = true
+			i++
+		case "FROMLONLAT":
+			if hasFrom || i+2 >= len(args) {
+				return "", ErrSyntaxIncorrect
+			}
+			if opts.Longitude, opts.Latitude, err = parseLonLat(args[i+1], args[i+2]); err != nil {
+				return
+			}
+			hasFrom = true
+			i += 2
+		case "BYRADIUS":
+			if hasBy || i+2 >= len(args) {
+				return "", ErrSyntaxIncorrect
+			}
+			if opts.Radius, err = strconv.ParseFloat(args[i+1], 64); err != nil || opts.Radius <= 0 {
+				return "", ErrSyntaxIncorrect
+			}
+			opts.Unit = args[i+2]
+			hasBy = true
+			i += 2
+		case "BYBOX":
+			if hasBy || i+3 >= len(args) {
+				return "", ErrSyntaxIncorrect
+			}
+			if opts.Width, err = strconv.ParseFloat(args[i+1], 64); err != nil || opts.Width <= 0 {
+				return "", ErrSyntaxIncorrect
+			}
+			if opts.Height, err = strconv.ParseFloat(args[i+2], 64); err != nil || opts.Height <= 0 {
+				return "", ErrSyntaxIncorrect
+			}
+			opts.Unit = args[i+3]
+			hasBy = true
+			i += 3
+		case "ASC":
+			opts.Desc = false
+		case "DESC":
+			opts.Desc = true
+		case "COUNT":
+			if i+1 >= len(args) {
+				return "", ErrSyntaxIncorrect
+			}
+			if opts.Count, err = strconv.Atoi(args[i+1]); err != nil || opts.Count <= 0 {
+				return "", ErrSyntaxIncorrect
+			}
+			i++
+		case "WITHCOORD":
+			withCoord = true
+		case "WITHDIST":
+			withDist = true
+		case "WITHHASH":
+			withHash = true
+		default:
+			return "", ErrSyntaxIncorrect
+		}
+	}
+	if !hasFrom || !hasBy {
+		return "", ErrSyntaxIncorrect
+	}
+
+	val, err := db.GeoSearch([]byte(args[0]), opts)
+	if err != nil {
+		return
+	}
+	for i, r := range val {
+		res += string(r.Member)
+		if withDist {
+			res += " " + strconv.FormatFloat(r.Distance, 'f', 4, 64)
+		}
+		if withHash {
+			res += " " + strconv.FormatUint(r.Hash, 10)
+		}
+		if withCoord {
+			res += " " + formatLonLat(r.Longitude, r.Latitude)
+		}
+		if i != len(val)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+func parseLonLat(lonArg, latArg string) (lon, lat float64, err error) {
+	if lon, err = strconv.ParseFloat(lonArg, 64); err != nil {
+		return 0, 0, ErrSyntaxIncorrect
+	}
+	if lat, err = strconv.ParseFloat(latArg, 64); err != nil {
+		return 0, 0, ErrSyntaxIncorrect
+	}
+	return
+}
+
+func formatLonLat(lon, lat float64) string {
+	return strconv.FormatFloat(lon, 'f', -1, 64) + " " + strconv.FormatFloat(lat, 'f', -1, 64)
+}
+
+func init() {
+	addExecCommand("geoadd", geoAdd)
+	addExecCommand("geopos", geoPos)
+	addExecCommand("geodist", geoDist)
+	addExecCommand("geosearch", geoSearch)
+}
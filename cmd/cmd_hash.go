@@ -239,6 +239,108 @@ func hValues(db *KV_Storage.KvDB, args []string) (res string, err error) {
 
 }
 
+func hScan(db *KV_Storage.KvDB, args []string) (res string, err error) {
+
+	if len(args) < 2 {
+
+		err = ErrSyntaxIncorrect
+
+		return
+
+	}
+
+	pattern, count, err := parseScanArgs(args[2:])
+
+	if err != nil {
+
+		return
+
+	}
+
+	var next string
+
+	var val [][]byte
+
+	if next, val, err = db.HScan([]byte(args[0]), args[1], pattern, count); err == nil {
+
+		res = next
+
+		for _, v := range val {
+
+			res += "\n" + string(v)
+
+		}
+
+	}
+
+	return
+
+}
+
+func hIncrBy(db *KV_Storage.KvDB, args []string) (res string, err error) {
+
+	if len(args) != 3 {
+
+		err = ErrSyntaxIncorrect
+
+		return
+
+	}
+
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+
+	if err != nil {
+
+		err = ErrSyntaxIncorrect
+
+		return
+
+	}
+
+	var val int64
+
+	if val, err = db.HIncrBy([]byte(args[0]), []byte(args[1]), delta); err == nil {
+
+		res = strconv.FormatInt(val, 10)
+
+	}
+
+	return
+
+}
+
+func hIncrByFloat(db *KV_Storage.KvDB, args []string) (res string, err error) {
+
+	if len(args) != 3 {
+
+		err = ErrSyntaxIncorrect
+
+		return
+
+	}
+
+	delta, err := strconv.ParseFloat(args[2], 64)
+
+	if err != nil {
+
+		err = ErrSyntaxIncorrect
+
+		return
+
+	}
+
+	var val float64
+
+	if val, err = db.HIncrByFloat([]byte(args[0]), []byte(args[1]), delta); err == nil {
+
+		res = strconv.FormatFloat(val, 'f', -1, 64)
+
+	}
+
+	return
+
+}
+
 func init() {
 
 	addExecCommand("hset", hSet)
@@ -259,4 +361,10 @@ func init() {
 
 	addExecCommand("hvalues", hValues)
 
+	addExecCommand("hscan", hScan)
+
+	addExecCommand("hincrby", hIncrBy)
+
+	addExecCommand("hincrbyfloat", hIncrByFloat)
+
 }
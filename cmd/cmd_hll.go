@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"KV_Storage"
+	"strconv"
+)
+
+func pfAdd(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var elements [][]byte
+	for _, e := range args[1:] {
+		elements = append(elements, []byte(e))
+	}
+	var updated bool
+	if updated, err = db.PFAdd([]byte(args[0]), elements...); err == nil {
+		if updated {
+			res = "1"
+		} else {
+			res = "0"
+		}
+	}
+	return
+}
+
+func pfCount(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var keys [][]byte
+	for _, k := range args {
+		keys = append(keys, []byte(k))
+	}
+	var count uint64
+	if count, err = db.PFCount(keys...); err == nil {
+		res = strconv.FormatUint(count, 10)
+	}
+	return
+}
+
+func pfMerge(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var keys [][]byte
+	for _, k := range args[1:] {
+		keys = append(keys, []byte(k))
+	}
+	if err = db.PFMerge([]byte(args[0]), keys...); err == nil {
+		res = "OK"
+	}
+	return
+}
+
+func init() {
+	addExecCommand("pfadd", pfAdd)
+	addExecCommand("pfcount", pfCount)
+	addExecCommand("pfmerge", pfMerge)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"KV_Storage"
+	"strconv"
+	"strings"
+)
+
+// JSON.SET key path value [NX|XX]
+func jsonSet(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 3 && len(args) != 4 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var nx, xx bool
+	if len(args) == 4 {
+		switch strings.ToUpper(args[3]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		default:
+			return "", ErrSyntaxIncorrect
+		}
+	}
+
+	var ok bool
+	if ok, err = db.JSONSet([]byte(args[0]), unquoteJSONArg(args[1]), []byte(unquoteJSONArg(args[2])), nx, xx); err == nil {
+		if ok {
+			res = "OK"
+		} else {
+			res = "<nil>"
+		}
+	}
+	return
+}
+
+// JSON.GET key [path ...]
+func jsonGet(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var paths []string
+	for _, path := range args[1:] {
+		paths = append(paths, unquoteJSONArg(path))
+	}
+
+	var val []byte
+	if val, err = db.JSONGet([]byte(args[0]), paths...); err == nil {
+		if val == nil {
+			res = "<nil>"
+		} else {
+			res = string(val)
+		}
+	}
+	return
+}
+
+// JSON.DEL key [path]
+func jsonDel(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 && len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	path := ""
+	if len(args) == 2 {
+		path = unquoteJSONArg(args[1])
+	}
+
+	var count int
+	if count, err = db.JSONDel([]byte(args[0]), path); err == nil {
+		res = strconv.Itoa(count)
+	}
+	return
+}
+
+// JSON.NUMINCRBY key path value
+func jsonNumIncrBy(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 3 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	res, err = db.JSONNumIncrBy([]byte(args[0]), unquoteJSONArg(args[1]), args[2])
+	return
+}
+
+// 去掉路径或JSON值两侧的单引号，含有空格的参数可以用单引号包裹，如 '{"a": 1}'
+func unquoteJSONArg(arg string) string {
+	if len(arg) >= 2 && arg[0] == '\'' && arg[len(arg)-1] == '\'' {
+		return arg[1 : len(arg)-1]
+	}
+	return arg
+}
+
+func init() {
+	addExecCommand("json.set", jsonSet)
+	addExecCommand("json.get", jsonGet)
+	addExecCommand("json.del", jsonDel)
+	addExecCommand("json.numincrby", jsonNumIncrBy)
+}
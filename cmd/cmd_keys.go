@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"KV_Storage"
+	"strconv"
+	"strings"
+)
+
+func del(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var keys [][]byte
+	for _, k := range args {
+		keys = append(keys, []byte(k))
+	}
+	var count int
+	if count, err = db.Del(keys...); err == nil {
+		res = strconv.Itoa(count)
+	}
+	return
+}
+
+func exists(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var keys [][]byte
+	for _, k := range args {
+		keys = append(keys, []byte(k))
+	}
+	res = strconv.Itoa(db.Exists(keys...))
+	return
+}
+
+func keyType(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	res = db.Type([]byte(args[0]))
+	return
+}
+
+func keys(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	val := db.Keys([]byte(args[0]))
+	for i, v := range val {
+		res += string(v)
+		if i != len(val)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+func rename(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	if err = db.Rename([]byte(args[0]), []byte(args[1])); err == nil {
+		res = "OK"
+	}
+	return
+}
+
+func renameNx(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var ok bool
+	if ok, err = db.RenameNX([]byte(args[0]), []byte(args[1])); err == nil {
+		if ok {
+			res = "1"
+		} else {
+			res = "0"
+		}
+	}
+	return
+}
+
+func dbSize(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 0 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	res = strconv.Itoa(db.DBSize())
+	return
+}
+
+func flushDB(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 0 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	if err = db.FlushDB(); err == nil {
+		res = "OK"
+	}
+	return
+}
+
+func scan(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	pattern, count, err := parseScanArgs(args[1:])
+	if err != nil {
+		return
+	}
+
+	var next string
+	var val [][]byte
+	if next, val, err = db.Scan(args[0], pattern, count); err == nil {
+		res = next
+		for _, v := range val {
+			res += "\n" + string(v)
+		}
+	}
+	return
+}
+
+// 解析 SCAN 系列命令的可选参数：[MATCH pattern] [COUNT count]
+func parseScanArgs(args []string) (pattern []byte, count int, err error) {
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, 0, ErrSyntaxIncorrect
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = []byte(args[i+1])
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				return nil, 0, ErrSyntaxIncorrect
+			}
+		default:
+			return nil, 0, ErrSyntaxIncorrect
+		}
+	}
+	return
+}
+
+func init() {
+	addExecCommand("del", del)
+	addExecCommand("exists", exists)
+	addExecCommand("type", keyType)
+	addExecCommand("keys", keys)
+	addExecCommand("rename", rename)
+	addExecCommand("renamenx", renameNx)
+	addExecCommand("dbsize", dbSize)
+	addExecCommand("flushdb", flushDB)
+	addExecCommand("scan", scan)
+}
@@ -3,7 +3,10 @@ package cmd
 import (
 	"KV_Storage"
 	"KV_Storage/ds/list"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func lPush(db *KV_Storage.KvDB, args []string) (res string, err error) {
@@ -203,6 +206,205 @@ func lLen(db *KV_Storage.KvDB, args []string) (res string, err error) {
 	return
 }
 
+func lPushX(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return pushX(db, args, db.LPushX)
+}
+
+func rPushX(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return pushX(db, args, db.RPushX)
+}
+
+// for lPushX and rPushX
+func pushX(db *KV_Storage.KvDB, args []string, push func([]byte, ...[]byte) (int, error)) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var values [][]byte
+	for i := 1; i < len(args); i++ {
+		values = append(values, []byte(args[i]))
+	}
+
+	var val int
+	if val, err = push([]byte(args[0]), values...); err == nil {
+		res = strconv.Itoa(val)
+	}
+	return
+}
+
+// LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func lMove(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 4 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	from, ok1 := parseListDirection(args[2])
+	to, ok2 := parseListDirection(args[3])
+	if !ok1 || !ok2 {
+		return "", ErrSyntaxIncorrect
+	}
+
+	var val []byte
+	if val, err = db.LMove([]byte(args[0]), []byte(args[1]), from, to); err == nil {
+		if val == nil {
+			res = "<nil>"
+		} else {
+			res = string(val)
+		}
+	}
+	return
+}
+
+func rPopLPush(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var val []byte
+	if val, err = db.RPopLPush([]byte(args[0]), []byte(args[1])); err == nil {
+		if val == nil {
+			res = "<nil>"
+		} else {
+			res = string(val)
+		}
+	}
+	return
+}
+
+// LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+// 不指定 COUNT 时返回第一个匹配的下标或 <nil>，否则每个匹配的下标占一行
+func lPos(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 2 || len(args)%2 != 0 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	rank, count, maxLen, withCount := 1, 1, 0, false
+	for i := 2; i < len(args); i += 2 {
+		var n int
+		if n, err = strconv.Atoi(args[i+1]); err != nil {
+			return "", ErrSyntaxIncorrect
+		}
+		switch strings.ToUpper(args[i]) {
+		case "RANK":
+			rank = n
+		case "COUNT":
+			count, withCount = n, true
+		case "MAXLEN":
+			maxLen = n
+		default:
+			return "", ErrSyntaxIncorrect
+		}
+	}
+
+	var positions []int
+	if positions, err = db.LPos([]byte(args[0]), []byte(args[1]), rank, count, maxLen); err != nil {
+		return
+	}
+	if !withCount {
+		if len(positions) == 0 {
+			return "<nil>", nil
+		}
+		return strconv.Itoa(positions[0]), nil
+	}
+	for i, pos := range positions {
+		res += strconv.Itoa(pos)
+		if i != len(positions)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+func bLPop(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return bPop(db, args, db.BLPop)
+}
+
+func bRPop(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return bPop(db, args, db.BRPop)
+}
+
+// for bLPop and bRPop
+// BLPOP key [key ...] timeout，依次返回取出元素的列表的key和元素，超时返回 <nil>
+func bPop(db *KV_Storage.KvDB, args []string, pop func(time.Duration, ...[]byte) ([]byte, []byte, error)) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	timeout, err := parseBlockTimeout(args[len(args)-1])
+	if err != nil {
+		return
+	}
+	var keys [][]byte
+	for _, k := range args[:len(args)-1] {
+		keys = append(keys, []byte(k))
+	}
+
+	var key, val []byte
+	if key, val, err = pop(timeout, keys...); err == nil {
+		if key == nil {
+			res = "<nil>"
+		} else {
+			res = string(key) + "\n" + string(val)
+		}
+	}
+	return
+}
+
+// BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func bLMove(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 5 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	from, ok1 := parseListDirection(args[2])
+	to, ok2 := parseListDirection(args[3])
+	if !ok1 || !ok2 {
+		return "", ErrSyntaxIncorrect
+	}
+	timeout, err := parseBlockTimeout(args[4])
+	if err != nil {
+		return
+	}
+
+	var val []byte
+	if val, err = db.BLMove([]byte(args[0]), []byte(args[1]), from, to, timeout); err == nil {
+		if val == nil {
+			res = "<nil>"
+		} else {
+			res = string(val)
+		}
+	}
+	return
+}
+
+func parseListDirection(s string) (KV_Storage.ListDirection, bool) {
+	switch strings.ToUpper(s) {
+	case "LEFT":
+		return KV_Storage.ListLeft, true
+	case "RIGHT":
+		return KV_Storage.ListRight, true
+	}
+	return 0, false
+}
+
+// 解析以秒为单位的阻塞超时时间，可以是小数，0表示一直阻塞
+func parseBlockTimeout(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, ErrSyntaxIncorrect
+	}
+	if seconds < 0 || math.IsNaN(seconds) || seconds > float64(math.MaxInt64/int64(time.Second)) {
+		return 0, KV_Storage.ErrInvalidTimeout
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 func init() {
 	addExecCommand("lpush", lPush)
 	addExecCommand("rpush", rPush)
@@ -215,4 +417,12 @@ func init() {
 	addExecCommand("ltrim", lTrim)
 	addExecCommand("lrange", lRange)
 	addExecCommand("llen", lLen)
+	addExecCommand("lpushx", lPushX)
+	addExecCommand("rpushx", rPushX)
+	addExecCommand("lmove", lMove)
+	addExecCommand("rpoplpush", rPopLPush)
+	addExecCommand("lpos", lPos)
+	addExecCommand("blpop", bLPop)
+	addExecCommand("brpop", bRPop)
+	addExecCommand("blmove", bLMove)
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"KV_Storage"
 	"strconv"
+	"strings"
 )
 
 func sAdd(db *KV_Storage.KvDB, args []string) (res string, err error) {
@@ -167,6 +168,132 @@ func sDiff(db *KV_Storage.KvDB, args []string) (res string, err error) {
 	return
 }
 
+func sInter(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) <= 0 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var keys [][]byte
+	for _, v := range args {
+		keys = append(keys, []byte(v))
+	}
+	val := db.SInter(keys...)
+	for i, v := range val {
+		res += string(v)
+		if i != len(val)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+func sInterStore(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return sStore(args, db.SInterStore)
+}
+
+func sUnionStore(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return sStore(args, db.SUnionStore)
+}
+
+func sDiffStore(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return sStore(args, db.SDiffStore)
+}
+
+// for sInterStore, sUnionStore and sDiffStore
+// SINTERSTORE destination key [key ...]，返回结果集的元素个数
+func sStore(args []string, store func([]byte, ...[]byte) (int, error)) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var keys [][]byte
+	for _, v := range args[1:] {
+		keys = append(keys, []byte(v))
+	}
+
+	var count int
+	if count, err = store([]byte(args[0]), keys...); err == nil {
+		res = strconv.Itoa(count)
+	}
+	return
+}
+
+// SMISMEMBER key member [member ...]，每个元素的结果占一行
+func sMIsMember(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var members [][]byte
+	for _, v := range args[1:] {
+		members = append(members, []byte(v))
+	}
+
+	val := db.SMIsMember([]byte(args[0]), members...)
+	for i, ok := range val {
+		if ok {
+			res += "1"
+		} else {
+			res += "0"
+		}
+		if i != len(val)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+// SINTERCARD numkeys key [key ...] [LIMIT limit]
+func sInterCard(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys <= 0 || numKeys > len(args)-1 {
+		return "", ErrSyntaxIncorrect
+	}
+
+	limit := 0
+	rest := args[1+numKeys:]
+	switch {
+	case len(rest) == 2 && strings.ToUpper(rest[0]) == "LIMIT":
+		if limit, err = strconv.Atoi(rest[1]); err != nil || limit < 0 {
+			return "", ErrSyntaxIncorrect
+		}
+	case len(rest) != 0:
+		return "", ErrSyntaxIncorrect
+	}
+
+	var keys [][]byte
+	for _, v := range args[1 : 1+numKeys] {
+		keys = append(keys, []byte(v))
+	}
+	res = strconv.Itoa(db.SInterCard(limit, keys...))
+	return
+}
+
+func sScan(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	pattern, count, err := parseScanArgs(args[2:])
+	if err != nil {
+		return
+	}
+
+	var next string
+	var val [][]byte
+	if next, val, err = db.SScan([]byte(args[0]), args[1], pattern, count); err == nil {
+		res = next
+		for _, v := range val {
+			res += "\n" + string(v)
+		}
+	}
+	return
+}
+
 func init() {
 	addExecCommand("sadd", sAdd)
 	addExecCommand("spop", sPop)
@@ -178,4 +305,11 @@ func init() {
 	addExecCommand("smembers", sMembers)
 	addExecCommand("sunion", sUnion)
 	addExecCommand("sdiff", sDiff)
+	addExecCommand("sinter", sInter)
+	addExecCommand("sinterstore", sInterStore)
+	addExecCommand("sunionstore", sUnionStore)
+	addExecCommand("sdiffstore", sDiffStore)
+	addExecCommand("smismember", sMIsMember)
+	addExecCommand("sintercard", sInterCard)
+	addExecCommand("sscan", sScan)
 }
@@ -3,6 +3,8 @@ package cmd
 import (
 	"KV_Storage"
 	"errors"
+	"strings"
+	"time"
 
 	"strconv"
 )
@@ -10,14 +12,69 @@ import (
 var ErrSyntaxIncorrect = errors.New("syntax err")
 
 func set(db *KV_Storage.KvDB, args []string) (res string, err error) {
-	if len(args) != 2 {
+	if len(args) < 2 {
 		err = ErrSyntaxIncorrect
 		return
 	}
 
 	key, value := args[0], args[1]
-	if err = db.Set([]byte(key), []byte(value)); err == nil {
-		res = "OK"
+	if len(args) == 2 {
+		if err = db.Set([]byte(key), []byte(value)); err == nil {
+			res = "OK"
+		}
+		return
+	}
+
+	var opts KV_Storage.SetOptions
+	if opts, err = parseSetOptions(args[2:]); err != nil {
+		return
+	}
+
+	var ok bool
+	if ok, err = db.SetWithOptions([]byte(key), []byte(value), opts); err == nil {
+		if ok {
+			res = "OK"
+		} else {
+			res = "<nil>"
+		}
+	}
+	return
+}
+
+// 解析 SET 命令的可选参数：[EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp] [NX|XX] [KEEPTTL]
+func parseSetOptions(args []string) (opts KV_Storage.SetOptions, err error) {
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "KEEPTTL":
+			opts.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if opts.ExpireAt != 0 || i+1 >= len(args) {
+				return opts, ErrSyntaxIncorrect
+			}
+			var n int64
+			if n, err = strconv.ParseInt(args[i+1], 10, 64); err != nil || n <= 0 {
+				return opts, ErrSyntaxIncorrect
+			}
+
+			nowMs := time.Now().UnixNano() / int64(time.Millisecond)
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				opts.ExpireAt = nowMs + n*1000
+			case "PX":
+				opts.ExpireAt = nowMs + n
+			case "EXAT":
+				opts.ExpireAt = n * 1000
+			case "PXAT":
+				opts.ExpireAt = n
+			}
+			i++
+		default:
+			return opts, ErrSyntaxIncorrect
+		}
 	}
 	return
 }
@@ -125,14 +182,9 @@ func prefixScan(db *KV_Storage.KvDB, args []string) (res string, err error) {
 		return
 	}
 
-	var val [][]byte
-	if val, err = db.PrefixScan(args[0], limit, offset); err == nil {
-		for i, v := range val {
-			res += string(v)
-			if i != len(val)-1 {
-				res += "\n"
-			}
-		}
+	var kvs []KV_Storage.KV
+	if kvs, err = db.PrefixScan(args[0], limit, offset); err == nil {
+		res = joinKVs(kvs, true)
 	}
 	return
 }
@@ -143,15 +195,85 @@ func rangeScan(db *KV_Storage.KvDB, args []string) (res string, err error) {
 		return
 	}
 
-	var val [][]byte
-	if val, err = db.RangeScan([]byte(args[0]), []byte(args[1])); err == nil {
-		for i, v := range val {
-			res += string(v)
-			if i != len(val)-1 {
-				res += "\n"
+	var kvs []KV_Storage.KV
+	if kvs, err = db.RangeScan([]byte(args[0]), []byte(args[1])); err == nil {
+		res = joinKVs(kvs, true)
+	}
+	return
+}
+
+// 将键值对按行拼接，withKeys 为 true 时 key 和 value 交替排列，否则只包含 value
+func joinKVs(kvs []KV_Storage.KV, withKeys bool) (res string) {
+	for i, kv := range kvs {
+		if withKeys {
+			res += string(kv.Key) + "\n"
+		}
+		res += string(kv.Value)
+		if i != len(kvs)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+// RANGE start end [REV] [LIMIT n] [WITHKEYS]
+func rangeKV(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var opts KV_Storage.RangeOptions
+	if opts.Start, opts.StartExclusive, err = parseRangeBound(args[0], "-"); err != nil {
+		return
+	}
+	if opts.End, opts.EndExclusive, err = parseRangeBound(args[1], "+"); err != nil {
+		return
+	}
+
+	withKeys := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "REV":
+			opts.Reverse = true
+		case "WITHKEYS":
+			withKeys = true
+		case "LIMIT":
+			if i+1 >= len(args) {
+				return "", ErrSyntaxIncorrect
 			}
+			if opts.Limit, err = strconv.Atoi(args[i+1]); err != nil || opts.Limit <= 0 {
+				return "", ErrSyntaxIncorrect
+			}
+			i++
+		default:
+			return "", ErrSyntaxIncorrect
 		}
 	}
+
+	var kvs []KV_Storage.KV
+	if kvs, err = db.Range(opts); err == nil {
+		res = joinKVs(kvs, withKeys)
+	}
+	return
+}
+
+// 解析范围的边界：[key 表示包含，(key 表示不包含，unbounded（- 或 +）表示不限制，不带前缀时视为包含
+func parseRangeBound(arg, unbounded string) (key []byte, exclusive bool, err error) {
+	switch {
+	case arg == unbounded:
+		return nil, false, nil
+	case strings.HasPrefix(arg, "("):
+		key, exclusive = []byte(arg[1:]), true
+	case strings.HasPrefix(arg, "["):
+		key = []byte(arg[1:])
+	default:
+		key = []byte(arg)
+	}
+
+	if len(key) == 0 {
+		err = ErrSyntaxIncorrect
+	}
 	return
 }
 
@@ -176,14 +298,16 @@ func persist(db *KV_Storage.KvDB, args []string) (res string, err error) {
 		err = ErrSyntaxIncorrect
 		return
 	}
-	db.Persist([]byte(args[0]))
-	res = "OK"
+	if err = db.Persist([]byte(args[0])); err == nil {
+		res = "OK"
+	}
 	return
 }
 
 func ttl(db *KV_Storage.KvDB, args []string) (res string, err error) {
 	if len(args) != 1 {
 		err = ErrSyntaxIncorrect
+		return
 	}
 
 	ttl := db.TTL([]byte(args[0]))
@@ -191,6 +315,425 @@ func ttl(db *KV_Storage.KvDB, args []string) (res string, err error) {
 	return
 }
 
+func pExpire(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	milliseconds, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	if err = db.PExpire([]byte(args[0]), milliseconds); err == nil {
+		res = "OK"
+	}
+	return
+}
+
+func expireAt(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return rawExpireAt(db, args, false)
+}
+
+func pExpireAt(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return rawExpireAt(db, args, true)
+}
+
+// for expireAt and pExpireAt
+func rawExpireAt(db *KV_Storage.KvDB, args []string, millis bool) (res string, err error) {
+	if len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	timestamp, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	if millis {
+		err = db.PExpireAt([]byte(args[0]), timestamp)
+	} else {
+		err = db.ExpireAt([]byte(args[0]), timestamp)
+	}
+	if err == nil {
+		res = "OK"
+	}
+	return
+}
+
+func pTTL(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	ttl := db.PTTL([]byte(args[0]))
+	res = strconv.FormatUint(ttl, 10)
+	return
+}
+
+func mGet(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var keys [][]byte
+	for _, k := range args {
+		keys = append(keys, []byte(k))
+	}
+	var val [][]byte
+	if val, err = db.MGet(keys...); err == nil {
+		for i, v := range val {
+			if v == nil {
+				res += "<nil>"
+			} else {
+				res += string(v)
+			}
+			if i != len(val)-1 {
+				res += "\n"
+			}
+		}
+	}
+	return
+}
+
+func mSet(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	var kvs []KV_Storage.KV
+	if kvs, err = parseKVs(args); err != nil {
+		return
+	}
+	if err = db.MSet(kvs...); err == nil {
+		res = "OK"
+	}
+	return
+}
+
+func mSetNx(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	var kvs []KV_Storage.KV
+	if kvs, err = parseKVs(args); err != nil {
+		return
+	}
+	var ok bool
+	if ok, err = db.MSetNX(kvs...); err == nil {
+		if ok {
+			res = "1"
+		} else {
+			res = "0"
+		}
+	}
+	return
+}
+
+// 将 key value [key value...] 形式的参数解析为键值对
+func parseKVs(args []string) (kvs []KV_Storage.KV, err error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, ErrSyntaxIncorrect
+	}
+	for i := 0; i < len(args); i += 2 {
+		kvs = append(kvs, KV_Storage.KV{Key: []byte(args[i]), Value: []byte(args[i+1])})
+	}
+	return
+}
+
+func getRange(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 3 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	end, err := strconv.Atoi(args[2])
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var val []byte
+	if val, err = db.GetRange([]byte(args[0]), start, end); err == nil {
+		res = string(val)
+	}
+	return
+}
+
+func setRange(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 3 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	offset, err := strconv.Atoi(args[1])
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var length int
+	if length, err = db.SetRange([]byte(args[0]), offset, []byte(args[2])); err == nil {
+		res = strconv.Itoa(length)
+	}
+	return
+}
+
+func getDel(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var val []byte
+	if val, err = db.GetDel([]byte(args[0])); err == nil {
+		res = string(val)
+	}
+	return
+}
+
+// GETEX key [EX seconds|PX milliseconds|EXAT timestamp|PXAT milliseconds-timestamp|PERSIST]
+func getEx(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var opts KV_Storage.GetExOptions
+	if len(args) == 2 && strings.ToUpper(args[1]) == "PERSIST" {
+		opts.Persist = true
+	} else if len(args) > 1 {
+		var setOpts KV_Storage.SetOptions
+		if setOpts, err = parseSetOptions(args[1:]); err != nil {
+			return
+		}
+		if setOpts.NX || setOpts.XX || setOpts.KeepTTL {
+			return "", ErrSyntaxIncorrect
+		}
+		opts.ExpireAt = setOpts.ExpireAt
+	}
+
+	var val []byte
+	if val, err = db.GetEx([]byte(args[0]), opts); err == nil {
+		res = string(val)
+	}
+	return
+}
+
+func setBit(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 3 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	offset, err := strconv.Atoi(args[1])
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	bit, err := strconv.Atoi(args[2])
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var old int
+	if old, err = db.SetBit([]byte(args[0]), offset, bit); err == nil {
+		res = strconv.Itoa(old)
+	}
+	return
+}
+
+func getBit(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	offset, err := strconv.Atoi(args[1])
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var bit int
+	if bit, err = db.GetBit([]byte(args[0]), offset); err == nil {
+		res = strconv.Itoa(bit)
+	}
+	return
+}
+
+// BITCOUNT key [start end [BYTE|BIT]]
+func bitCount(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 && len(args) != 3 && len(args) != 4 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var r *KV_Storage.BitRange
+	if r, err = parseBitRange(args[1:]); err != nil {
+		return
+	}
+
+	var count int
+	if count, err = db.BitCount([]byte(args[0]), r); err == nil {
+		res = strconv.Itoa(count)
+	}
+	return
+}
+
+// BITPOS key bit [start [end [BYTE|BIT]]]
+func bitPos(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 2 || len(args) > 5 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	bit, err := strconv.Atoi(args[1])
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var r *KV_Storage.BitRange
+	if r, err = parseBitRange(args[2:]); err != nil {
+		return
+	}
+
+	var pos int
+	if pos, err = db.BitPos([]byte(args[0]), bit, r); err == nil {
+		res = strconv.Itoa(pos)
+	}
+	return
+}
+
+// 解析位操作的范围：[start [end [BYTE|BIT]]]，没有参数时返回 nil
+func parseBitRange(args []string) (r *KV_Storage.BitRange, err error) {
+	if len(args) == 0 {
+		return
+	}
+
+	r = &KV_Storage.BitRange{}
+	if r.Start, err = strconv.Atoi(args[0]); err != nil {
+		return nil, ErrSyntaxIncorrect
+	}
+	if len(args) > 1 {
+		if r.End, err = strconv.Atoi(args[1]); err != nil {
+			return nil, ErrSyntaxIncorrect
+		}
+		r.HasEnd = true
+	}
+	if len(args) > 2 {
+		switch strings.ToUpper(args[2]) {
+		case "BIT":
+			r.Bit = true
+		case "BYTE":
+		default:
+			return nil, ErrSyntaxIncorrect
+		}
+	}
+	return
+}
+
+// BITOP AND|OR|XOR|NOT destkey key [key...]
+func bitOp(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 3 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var op KV_Storage.BitOperation
+	switch strings.ToUpper(args[0]) {
+	case "AND":
+		op = KV_Storage.BitAnd
+	case "OR":
+		op = KV_Storage.BitOr
+	case "XOR":
+		op = KV_Storage.BitXor
+	case "NOT":
+		op = KV_Storage.BitNot
+	default:
+		return "", ErrSyntaxIncorrect
+	}
+
+	var keys [][]byte
+	for _, k := range args[2:] {
+		keys = append(keys, []byte(k))
+	}
+	var length int
+	if length, err = db.BitOp(op, []byte(args[1]), keys...); err == nil {
+		res = strconv.Itoa(length)
+	}
+	return
+}
+
+func incr(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var val int64
+	if val, err = db.Incr([]byte(args[0])); err == nil {
+		res = strconv.FormatInt(val, 10)
+	}
+	return
+}
+
+func decr(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	var val int64
+	if val, err = db.Decr([]byte(args[0])); err == nil {
+		res = strconv.FormatInt(val, 10)
+	}
+	return
+}
+
+func incrBy(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return rawIncrBy(db, args, false)
+}
+
+func decrBy(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return rawIncrBy(db, args, true)
+}
+
+// for incrBy and decrBy
+func rawIncrBy(db *KV_Storage.KvDB, args []string, decr bool) (res string, err error) {
+	if len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var val int64
+	if decr {
+		val, err = db.DecrBy([]byte(args[0]), delta)
+	} else {
+		val, err = db.IncrBy([]byte(args[0]), delta)
+	}
+	if err == nil {
+		res = strconv.FormatInt(val, 10)
+	}
+	return
+}
+
+func incrByFloat(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	delta, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var val float64
+	if val, err = db.IncrByFloat([]byte(args[0]), delta); err == nil {
+		res = strconv.FormatFloat(val, 'f', -1, 64)
+	}
+	return
+}
+
 func init() {
 	addExecCommand("set", set)
 	addExecCommand("get", get)
@@ -202,7 +745,29 @@ func init() {
 	addExecCommand("strrem", strRem)
 	addExecCommand("prefixscan", prefixScan)
 	addExecCommand("rangescan", rangeScan)
+	addExecCommand("range", rangeKV)
 	addExecCommand("expire", expire)
 	addExecCommand("persist", persist)
 	addExecCommand("ttl", ttl)
+	addExecCommand("pexpire", pExpire)
+	addExecCommand("expireat", expireAt)
+	addExecCommand("pexpireat", pExpireAt)
+	addExecCommand("pttl", pTTL)
+	addExecCommand("incr", incr)
+	addExecCommand("decr", decr)
+	addExecCommand("incrby", incrBy)
+	addExecCommand("decrby", decrBy)
+	addExecCommand("incrbyfloat", incrByFloat)
+	addExecCommand("mget", mGet)
+	addExecCommand("mset", mSet)
+	addExecCommand("msetnx", mSetNx)
+	addExecCommand("getrange", getRange)
+	addExecCommand("setrange", setRange)
+	addExecCommand("getdel", getDel)
+	addExecCommand("getex", getEx)
+	addExecCommand("setbit", setBit)
+	addExecCommand("getbit", getBit)
+	addExecCommand("bitcount", bitCount)
+	addExecCommand("bitpos", bitPos)
+	addExecCommand("bitop", bitOp)
 }
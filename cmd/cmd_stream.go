@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"KV_Storage"
+	"KV_Storage/ds/stream"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func xAdd(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 4 || len(args)%2 != 0 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var fields [][]byte
+	for _, f := range args[2:] {
+		fields = append(fields, []byte(f))
+	}
+	res, err = db.XAdd([]byte(args[0]), args[1], fields...)
+	return
+}
+
+func xLen(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 1 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	res = strconv.Itoa(db.XLen([]byte(args[0])))
+	return
+}
+
+func xRange(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return xRawRange(db, args, false)
+}
+
+func xRevRange(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	return xRawRange(db, args, true)
+}
+
+// for xRange and xRevRange
+// 每条消息占一行，依次为ID以及交替排列的域和值
+func xRawRange(db *KV_Storage.KvDB, args []string, rev bool) (res string, err error) {
+	if len(args) != 3 && len(args) != 5 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	count := 0
+	if len(args) == 5 {
+		if strings.ToUpper(args[3]) != "COUNT" {
+			return "", ErrSyntaxIncorrect
+		}
+		if count, err = strconv.Atoi(args[4]); err != nil || count < 0 {
+			return "", ErrSyntaxIncorrect
+		}
+	}
+
+	var entries []stream.Entry
+	if rev {
+		entries, err = db.XRevRange([]byte(args[0]), args[1], args[2], count)
+	} else {
+		entries, err = db.XRange([]byte(args[0]), args[1], args[2], count)
+	}
+	if err != nil {
+		return
+	}
+	for i, e := range entries {
+		res += formatStreamEntry(e)
+		if i != len(entries)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+// XREAD [COUNT count] STREAMS key [key ...] id [id ...]
+// 每条消息占一行，依次为流的key、消息ID以及交替排列的域和值
+func xRead(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	count, _, keys, ids, err := parseStreamReadArgs(args, false)
+	if err != nil {
+		return
+	}
+
+	val, err := db.XRead(keys, ids, count)
+	if err != nil {
+		return
+	}
+	res = formatStreamEntries(val)
+	return
+}
+
+// XREADGROUP GROUP group consumer [COUNT count] [NOACK] STREAMS key [key ...] id [id ...]
+func xReadGroup(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 3 || strings.ToUpper(args[0]) != "GROUP" {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	group, consumer := args[1], args[2]
+
+	count, noAck, keys, ids, err := parseStreamReadArgs(args[3:], true)
+	if err != nil {
+		return
+	}
+
+	val, err := db.XReadGroup([]byte(group), []byte(consumer), keys, ids, count, noAck)
+	if err != nil {
+		return
+	}
+	res = formatStreamEntries(val)
+	return
+}
+
+// XGROUP CREATE key group id|$ [MKSTREAM]
+func xGroup(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 4 || len(args) > 5 || strings.ToUpper(args[0]) != "CREATE" {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	mkStream := false
+	if len(args) == 5 {
+		if strings.ToUpper(args[4]) != "MKSTREAM" {
+			return "", ErrSyntaxIncorrect
+		}
+		mkStream = true
+	}
+	if err = db.XGroupCreate([]byte(args[1]), []byte(args[2]), args[3], mkStream); err == nil {
+		res = "OK"
+	}
+	return
+}
+
+func xAck(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 3 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	var count int
+	if count, err = db.XAck([]byte(args[0]), []byte(args[1]), args[2:]...); err == nil {
+		res = strconv.Itoa(count)
+	}
+	return
+}
+
+// XPENDING key group [start end count [consumer]]
+// 只指定 key 和 group 时，依次返回未确认消息的个数、最小ID、最大ID，以及每个消费者未确认消息的个数
+// 否则每条未确认消息占一行，依次为消息ID、消费者、距离上次投递的毫秒数以及投递次数
+func xPending(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) != 2 && len(args) != 5 && len(args) != 6 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+
+	start, end, count := "-", "+", 0
+	var consumer []byte
+	if len(args) >= 5 {
+		start, end = args[2], args[3]
+		if count, err = strconv.Atoi(args[4]); err != nil || count < 0 {
+			return "", ErrSyntaxIncorrect
+		}
+		if len(args) == 6 {
+			consumer = []byte(args[5])
+		}
+	}
+
+	val, err := db.XPending([]byte(args[0]), []byte(args[1]), start, end, count, consumer)
+	if err != nil {
+		return
+	}
+
+	if len(args) == 2 {
+		res = strconv.Itoa(len(val))
+		if len(val) == 0 {
+			return
+		}
+		res += "\n" + val[0].ID.String() + "\n" + val[len(val)-1].ID.String()
+
+		counts := make(map[string]int)
+		for _, pe := range val {
+			counts[pe.Consumer]++
+		}
+		var consumers []string
+		for c := range counts {
+			consumers = append(consumers, c)
+		}
+		sort.Strings(consumers)
+		for _, c := range consumers {
+			res += "\n" + c + " " + strconv.Itoa(counts[c])
+		}
+		return
+	}
+
+	now := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	for i, pe := range val {
+		var idle uint64
+		if now > pe.DeliveryTime {
+			idle = now - pe.DeliveryTime
+		}
+		res += pe.ID.String() + " " + pe.Consumer + " " + strconv.FormatUint(idle, 10) + " " + strconv.FormatUint(pe.DeliveryCount, 10)
+		if i != len(val)-1 {
+			res += "\n"
+		}
+	}
+	return
+}
+
+// 解析 XREAD 系列命令的参数：[COUNT count] [NOACK] STREAMS key [key ...] id [id ...]，只有 allowNoAck 为 true 时才接受 NOACK
+func parseStreamReadArgs(args []string, allowNoAck bool) (count int, noAck bool, keys [][]byte, ids []string, err error) {
+	i := 0
+	for ; i < len(args) && strings.ToUpper(args[i]) != "STREAMS"; i++ {
+		switch strings.ToUpper(args[i]) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return 0, false, nil, nil, ErrSyntaxIncorrect
+			}
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 0 {
+				return 0, false, nil, nil, ErrSyntaxIncorrect
+			}
+			i++
+		case "NOACK":
+			if !allowNoAck {
+				return 0, false, nil, nil, ErrSyntaxIncorrect
+			}
+			noAck = true
+		default:
+			return 0, false, nil, nil, ErrSyntaxIncorrect
+		}
+	}
+	if i >= len(args) {
+		return 0, false, nil, nil, ErrSyntaxIncorrect
+	}
+
+	rest := args[i+1:]
+	if len(rest) == 0 || len(rest)%2 != 0 {
+		return 0, false, nil, nil, ErrSyntaxIncorrect
+	}
+	for _, k := range rest[:len(rest)/2] {
+		keys = append(keys, []byte(k))
+	}
+	ids = rest[len(rest)/2:]
+	return
+}
+
+func formatStreamEntries(val []KV_Storage.StreamEntries) (res string) {
+	first := true
+	for _, s := range val {
+		for _, e := range s.Entries {
+			if !first {
+				res += "\n"
+			}
+			first = false
+			res += string(s.Key) + " " + formatStreamEntry(e)
+		}
+	}
+	return
+}
+
+func formatStreamEntry(e stream.Entry) string {
+	res := e.ID.String()
+	for _, f := range e.Fields {
+		res += " " + string(f)
+	}
+	return res
+}
+
+func init() {
+	addExecCommand("xadd", xAdd)
+	addExecCommand("xlen", xLen)
+	addExecCommand("xrange", xRange)
+	addExecCommand("xrevrange", xRevRange)
+	addExecCommand("xread", xRead)
+	addExecCommand("xreadgroup", xReadGroup)
+	addExecCommand("xgroup", xGroup)
+	addExecCommand("xack", xAck)
+	addExecCommand("xpending", xPending)
+}
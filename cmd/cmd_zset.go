@@ -211,6 +211,27 @@ func zRawScoreRange(db *KV_Storage.KvDB, args []string, rev bool) (res string, e
 	return
 }
 
+func zScan(db *KV_Storage.KvDB, args []string) (res string, err error) {
+	if len(args) < 2 {
+		err = ErrSyntaxIncorrect
+		return
+	}
+	pattern, count, err := parseScanArgs(args[2:])
+	if err != nil {
+		return
+	}
+
+	var next string
+	var val []interface{}
+	if next, val, err = db.ZScan([]byte(args[0]), args[1], pattern, count); err == nil {
+		res = next
+		for _, v := range val {
+			res += "\n" + fmt.Sprintf("%v", v)
+		}
+	}
+	return
+}
+
 func init() {
 	addExecCommand("zadd", zAdd)
 	addExecCommand("zscore", zScore)
@@ -225,4 +246,5 @@ func init() {
 	addExecCommand("zrevgetbyrank", zRevGetByRank)
 	addExecCommand("zscorerange", zScoreRange)
 	addExecCommand("zrevscorerange", zSRevScoreRange)
+	addExecCommand("zscan", zScan)
 }
@@ -31,6 +31,15 @@ const (
 
 	// DefaultReclaimThreshold 默认回收磁盘空间的阈值，当已封存文件个数到达 4 时，可进行回收
 	DefaultReclaimThreshold = 4
+
+	// DefaultCacheCapacity 默认的 value 缓存容量 64MB，仅在 KeyOnlyRamMode 下生效
+	DefaultCacheCapacity = 64 * 1024 * 1024
+
+	// DefaultCacheShards 默认的 value 缓存分片数
+	DefaultCacheShards = 16
+
+	// DefaultActiveExpireHz 默认每秒执行主动过期的次数
+	DefaultActiveExpireHz = 10
 )
 
 // Config 数据库配置
@@ -44,6 +53,9 @@ type Config struct {
 	MaxValueSize     uint32               `json:"max_value_size" toml:"max_value_size"`
 	Sync             bool                 `json:"sync" toml:"sync"`                           //每次写数据是否持久化
 	ReclaimThreshold int                  `json:"reclaim_threshold" toml:"reclaim_threshold"` //回收磁盘空间的阈值
+	CacheCapacity    int64                `json:"cache_capacity" toml:"cache_capacity"`       //value缓存的容量（字节），0表示不使用缓存
+	CacheShards      int                  `json:"cache_shards" toml:"cache_shards"`           //value缓存的分片数
+	ActiveExpireHz   int                  `json:"active_expire_hz" toml:"active_expire_hz"`   //每秒执行主动过期的次数，0表示只进行惰性删除
 }
 
 // DefaultConfig 获取默认配置
@@ -58,5 +70,8 @@ func DefaultConfig() Config {
 		MaxValueSize:     DefaultMaxValueSize,
 		Sync:             false,
 		ReclaimThreshold: DefaultReclaimThreshold,
+		CacheCapacity:    DefaultCacheCapacity,
+		CacheShards:      DefaultCacheShards,
+		ActiveExpireHz:   DefaultActiveExpireHz,
 	}
 }
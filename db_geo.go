@@ -0,0 +1,282 @@
+package KV_Storage
+
+import (
+	"KV_Storage/storage"
+	"KV_Storage/utils"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 地理位置相关操作接口
+// 位置以有序集合的形式保存，成员的 score 为经纬度的 52 位 geohash 编码，因此也可以使用有序集合的接口进行操作
+
+// GeoLocation 一个带有经纬度的成员
+type GeoLocation struct {
+	Member    []byte
+	Longitude float64
+	Latitude  float64
+}
+
+// GeoSearchOptions GeoSearch 的查询条件
+// 查询的中心为成员 Member 的位置，Member 为空时为 (Longitude, Latitude)
+// Radius 大于0时查询圆形区域，否则查询宽为 Width 高为 Height 的矩形区域，距离的单位均为 Unit
+type GeoSearchOptions struct {
+	Member    []byte
+	Longitude float64
+	Latitude  float64
+	Radius    float64
+	Width     float64
+	Height    float64
+	Unit      string // m、km、mi 或 ft，为空时为 m
+	Count     int    // 最多返回的结果数，0表示不限制
+	Desc      bool   // 是否按距离从远到近排序
+}
+
+// GeoResult GeoSearch 查询到的一个成员
+type GeoResult struct {
+	Member    []byte
+	Distance  float64 // 到查询中心的距离，单位与查询条件相同
+	Hash      uint64  // 52 位的 geohash 编码，即有序集合中的 score
+	Longitude float64
+	Latitude  float64
+}
+
+// GeoAdd 将成员及其经纬度加入到有序集 key 中，已存在的成员会更新其位置
+// 经纬度超出范围时返回 ErrInvalidCoordinates，返回新加入的成员个数
+func (db *KvDB) GeoAdd(key []byte, locations ...GeoLocation) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+
+	scores := make([]float64, len(locations))
+	for i, loc := range locations {
+		if err := db.checkKeyValue(key, loc.Member); err != nil {
+			return 0, err
+		}
+		hash, ok := utils.GeoHashEncode(loc.Longitude, loc.Latitude, utils.GeoStepMax)
+		if !ok {
+			return 0, ErrInvalidCoordinates
+		}
+		scores[i] = float64(hash.Bits)
+	}
+
+	db.zsetIndex.mu.Lock()
+	defer db.zsetIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, ZSet)
+
+	added := 0
+	for i, loc := range locations {
+		oldScore := db.zsetIndex.indexes.ZScore(string(key), string(loc.Member))
+		if oldScore == scores[i] {
+			continue
+		}
+
+		extra := []byte(utils.Float64ToStr(scores[i]))
+		e := storage.NewEntry(key, loc.Member, extra, ZSet, ZSetZAdd)
+		if err := db.store(e); err != nil {
+			return added, err
+		}
+		db.zsetIndex.indexes.ZAdd(string(key), scores[i], string(loc.Member))
+		if oldScore == math.MinInt64 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// GeoPos 返回有序集 key 中成员的经纬度，不存在的成员对应的结果为 nil
+// 返回的经纬度是编码后所在网格的中心点，与加入时的值可能有微小的差别
+func (db *KvDB) GeoPos(key []byte, members ...[]byte) []*GeoLocation {
+	res := make([]*GeoLocation, len(members))
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return res
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return res
+	}
+
+	for i, member := range members {
+		if lon, lat, ok := db.geoMemberPos(key, member); ok {
+			res[i] = &GeoLocation{Member: member, Longitude: lon, Latitude: lat}
+		}
+	}
+	return res
+}
+
+// GeoDist 返回有序集 key 中两个成员之间的距离，单位为 unit，任一成员不存在时 ok 为 false
+func (db *KvDB) GeoDist(key, member1, member2 []byte, unit string) (dist float64, ok bool, err error) {
+	factor, err := geoUnitFactor(unit)
+	if err != nil {
+		return
+	}
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return
+	}
+
+	lon1, lat1, ok1 := db.geoMemberPos(key, member1)
+	lon2, lat2, ok2 := db.geoMemberPos(key, member2)
+	if !ok1 || !ok2 {
+		return
+	}
+	return utils.GeoDistance(lon1, lat1, lon2, lat2) / factor, true, nil
+}
+
+// GeoSearch 查询有序集 key 中位于指定圆形或矩形区域内的成员，结果按照到中心的距离排序
+// 中心成员不存在时返回 ErrGeoMemberNotExist
+func (db *KvDB) GeoSearch(key []byte, opts GeoSearchOptions) ([]GeoResult, error) {
+	factor, err := geoUnitFactor(opts.Unit)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Radius < 0 || opts.Width < 0 || opts.Height < 0 || opts.Count < 0 ||
+		(opts.Radius == 0 && (opts.Width == 0 || opts.Height == 0)) {
+		return nil, ErrInvalidGeoSearch
+	}
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		if opts.Member != nil {
+			return nil, ErrGeoMemberNotExist
+		}
+		return nil, nil
+	}
+
+	lon, lat := opts.Longitude, opts.Latitude
+	if opts.Member != nil {
+		var ok bool
+		if lon, lat, ok = db.geoMemberPos(key, opts.Member); !ok {
+			return nil, ErrGeoMemberNotExist
+		}
+	} else if _, ok := utils.GeoHashEncode(lon, lat, utils.GeoStepMax); !ok {
+		return nil, ErrInvalidCoordinates
+	}
+
+	// 距离统一换算为米
+	radius, width, height := opts.Radius*factor, opts.Width*factor, opts.Height*factor
+	if radius > 0 {
+		width, height = 2*radius, 2*radius
+	}
+
+	var res []GeoResult
+	for _, cell := range geoSearchCells(lon, lat, width, height) {
+		min, max := cell.ScoreRange()
+		members := db.zsetIndex.indexes.ZScoreRange(string(key), float64(min), float64(max-1))
+		for i := 0; i < len(members); i += 2 {
+			score := members[i+1].(float64)
+			plon, plat := utils.GeoHashDecodeScore(score)
+
+			var dist float64
+			var ok bool
+			if radius > 0 {
+				dist = utils.GeoDistance(lon, lat, plon, plat)
+				ok = dist <= radius
+			} else {
+				dist, ok = geoDistanceInBox(lon, lat, plon, plat, width, height)
+			}
+			if ok {
+				res = append(res, GeoResult{
+					Member:    []byte(members[i].(string)),
+					Distance:  dist / factor,
+					Hash:      uint64(score),
+					Longitude: plon,
+					Latitude:  plat,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if opts.Desc {
+			return res[i].Distance > res[j].Distance
+		}
+		return res[i].Distance < res[j].Distance
+	})
+	if opts.Count > 0 && len(res) > opts.Count {
+		res = res[:opts.Count]
+	}
+	return res, nil
+}
+
+// 返回成员的经纬度，调用方需持有 zsetIndex 的锁
+func (db *KvDB) geoMemberPos(key, member []byte) (lon, lat float64, ok bool) {
+	score := db.zsetIndex.indexes.ZScore(string(key), string(member))
+	if score == math.MinInt64 {
+		return
+	}
+	lon, lat = utils.GeoHashDecodeScore(score)
+	return lon, lat, true
+}
+
+// 返回能够覆盖以 (lon, lat) 为中心，宽 width 高 height 的矩形区域的网格，网格之间没有重复
+// 选择尽可能高的精度，使得中心所在的网格及其周围的 8 个网格能够覆盖整个区域
+func geoSearchCells(lon, lat, width, height float64) []utils.GeoHash {
+	radius := math.Sqrt(width*width+height*height) / 2
+	box := utils.GeoBoundingBox(lon, lat, width, height)
+
+	step := utils.GeoEstimateSteps(radius, lat)
+	center, _ := utils.GeoHashEncode(lon, lat, step)
+	for step > 1 {
+		area := center.Area()
+		cellLat, cellLon := area.LatMax-area.LatMin, area.LonMax-area.LonMin
+		if area.LatMax+cellLat >= box.LatMax && area.LatMin-cellLat <= box.LatMin &&
+			area.LonMax+cellLon >= box.LonMax && area.LonMin-cellLon <= box.LonMin {
+			break
+		}
+		step--
+		center, _ = utils.GeoHashEncode(lon, lat, step)
+	}
+
+	var cells []utils.GeoHash
+	seen := make(map[uint64]bool)
+	for _, cell := range center.Neighbors() {
+		if !seen[cell.Bits] {
+			seen[cell.Bits] = true
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// 判断点 (plon, plat) 是否位于以 (lon, lat) 为中心，宽 width 高 height 的矩形内，并返回两点之间的距离
+func geoDistanceInBox(lon, lat, plon, plat, width, height float64) (float64, bool) {
+	if utils.GeoLatDistance(plat, lat) > height/2 {
+		return 0, false
+	}
+	if utils.GeoDistance(plon, plat, lon, plat) > width/2 {
+		return 0, false
+	}
+	return utils.GeoDistance(lon, lat, plon, plat), true
+}
+
+// 返回距离单位对应的米数
+func geoUnitFactor(unit string) (float64, error) {
+	switch strings.ToLower(unit) {
+	case "", "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "mi":
+		return 1609.34, nil
+	case "ft":
+		return 0.3048, nil
+	}
+	return 0, ErrInvalidGeoUnit
+}
@@ -3,7 +3,9 @@ package KV_Storage
 import (
 	"KV_Storage/ds/hash"
 	"KV_Storage/storage"
+	"KV_Storage/utils"
 	"bytes"
+	"strconv"
 	"sync"
 )
 
@@ -38,6 +40,8 @@ func (db *KvDB) HSet(key, field, value []byte) (res int, err error) {
 	db.hashIndex.mu.Lock()
 	defer db.hashIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, Hash)
+
 	e := storage.NewEntry(key, value, field, Hash, HashHSet) // 构造一个entry写入到文件中
 	if err = db.store(e); err != nil {
 		return
@@ -59,6 +63,8 @@ func (db *KvDB) HSetNx(key, field, value []byte) (res bool, err error) {
 	db.hashIndex.mu.Lock()
 	defer db.hashIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, Hash)
+
 	if res = db.hashIndex.indexes.HSetNx(string(key), string(field), value); res {
 		e := storage.NewEntry(key, value, field, Hash, HashHSet)
 		if err = db.store(e); err != nil {
@@ -69,6 +75,61 @@ func (db *KvDB) HSetNx(key, field, value []byte) (res bool, err error) {
 	return
 }
 
+// HIncrBy 将哈希表 key 中域 field 的整数值加上 increment，域不存在时先初始化为0，返回相加之后的值
+// 值不是整数时返回 ErrValueNotInteger，结果超出 int64 范围时返回 ErrIncrOverflow
+func (db *KvDB) HIncrBy(key, field []byte, increment int64) (res int64, err error) {
+
+	if err = db.checkKeyValue(key, field); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, Hash)
+
+	val := db.hashIndex.indexes.HGet(string(key), string(field))
+	if res, err = incrInt(val, increment); err != nil {
+		return
+	}
+
+	err = db.hashSetValue(key, field, []byte(strconv.FormatInt(res, 10)))
+	return
+}
+
+// HIncrByFloat 将哈希表 key 中域 field 的浮点数值加上 increment，域不存在时先初始化为0，返回相加之后的值
+// 值不是浮点数时返回 ErrValueNotFloat，结果为 NaN 或无穷大时返回 ErrIncrOverflow
+func (db *KvDB) HIncrByFloat(key, field []byte, increment float64) (res float64, err error) {
+
+	if err = db.checkKeyValue(key, field); err != nil {
+		return
+	}
+
+	db.hashIndex.mu.Lock()
+	defer db.hashIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, Hash)
+
+	val := db.hashIndex.indexes.HGet(string(key), string(field))
+	if res, err = incrFloat(val, increment); err != nil {
+		return
+	}
+
+	err = db.hashSetValue(key, field, []byte(formatFloat(res)))
+	return
+}
+
+// 写入哈希表中域的值，调用方需持有 hashIndex 的写锁
+func (db *KvDB) hashSetValue(key, field, value []byte) error {
+	e := storage.NewEntry(key, value, field, Hash, HashHSet)
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	db.hashIndex.indexes.HSet(string(key), string(field), value)
+	return nil
+}
+
 // HGet 返回哈希表中给定域的值
 func (db *KvDB) HGet(key, field []byte) []byte {
 
@@ -79,6 +140,10 @@ func (db *KvDB) HGet(key, field []byte) []byte {
 	db.hashIndex.mu.RLock()
 	defer db.hashIndex.mu.RUnlock()
 
+	if db.isExpired(key, Hash) {
+		return nil
+	}
+
 	return db.hashIndex.indexes.HGet(string(key), string(field))
 }
 
@@ -92,9 +157,41 @@ func (db *KvDB) HGetAll(key []byte) [][]byte {
 	db.hashIndex.mu.RLock()
 	defer db.hashIndex.mu.RUnlock()
 
+	if db.isExpired(key, Hash) {
+		return nil
+	}
+
 	return db.hashIndex.indexes.HGetAll(string(key))
 }
 
+// HScan 基于游标迭代哈希表 key 中的域，返回的 fieldValues 中域和值交替排列
+// cursor、pattern 和 count 的含义与 Scan 相同，pattern 用于过滤域
+func (db *KvDB) HScan(key []byte, cursor string, pattern []byte, count int) (next string, fieldValues [][]byte, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	sc, err := newScanCursor(cursor, count)
+	if err != nil {
+		return
+	}
+
+	db.hashIndex.mu.RLock()
+	defer db.hashIndex.mu.RUnlock()
+
+	if db.isExpired(key, Hash) {
+		return "0", nil, nil
+	}
+
+	db.hashIndex.indexes.HScan(string(key), sc.after, sc.bounded, sc.add)
+	next, fields := sc.page()
+	for _, f := range fields {
+		if len(pattern) == 0 || utils.GlobMatch(pattern, []byte(f)) {
+			fieldValues = append(fieldValues, []byte(f), db.hashIndex.indexes.HGet(string(key), f))
+		}
+	}
+	return
+}
+
 // HDel 删除哈希表 key 中的一个或多个指定域，不存在的域将被忽略
 // 返回被成功移除的元素个数
 func (db *KvDB) HDel(key []byte, field ...[]byte) (res int, err error) {
@@ -110,6 +207,9 @@ func (db *KvDB) HDel(key []byte, field ...[]byte) (res int, err error) {
 	db.hashIndex.mu.Lock()
 	defer db.hashIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, Hash)
+	defer db.clearExpireIfEmpty(key, Hash)
+
 	for _, f := range field {
 		if ok := db.hashIndex.indexes.HDel(string(key), string(f)); ok {
 			e := storage.NewEntry(key, nil, f, Hash, HashHDel)
@@ -132,6 +232,10 @@ func (db *KvDB) HExists(key, field []byte) bool {
 	db.hashIndex.mu.RLock()
 	defer db.hashIndex.mu.RUnlock()
 
+	if db.isExpired(key, Hash) {
+		return false
+	}
+
 	return db.hashIndex.indexes.HExists(string(key), string(field))
 }
 
@@ -144,6 +248,10 @@ func (db *KvDB) HLen(key []byte) int {
 	db.hashIndex.mu.RLock()
 	defer db.hashIndex.mu.RUnlock()
 
+	if db.isExpired(key, Hash) {
+		return 0
+	}
+
 	return db.hashIndex.indexes.HLen(string(key))
 }
 
@@ -156,6 +264,10 @@ func (db *KvDB) HKeys(key []byte) (val []string) {
 	db.hashIndex.mu.RLock()
 	defer db.hashIndex.mu.RUnlock()
 
+	if db.isExpired(key, Hash) {
+		return
+	}
+
 	return db.hashIndex.indexes.HKeys(string(key))
 }
 
@@ -168,5 +280,9 @@ func (db *KvDB) HValues(key []byte) (val [][]byte) {
 	db.hashIndex.mu.RLock()
 	defer db.hashIndex.mu.RUnlock()
 
+	if db.isExpired(key, Hash) {
+		return
+	}
+
 	return db.hashIndex.indexes.HValues(string(key))
 }
@@ -0,0 +1,152 @@
+package KV_Storage
+
+import (
+	"KV_Storage/ds/hll"
+)
+
+// HyperLogLog 相关操作接口
+// HyperLogLog 以字符串的形式保存，格式与 Redis 相同，修改时整体写入一条 StringSet 记录，key 的过期时间保持不变
+
+// PFAdd 将元素加入 key 对应的 HyperLogLog，key 不存在时新建一个
+// 至少有一个内部寄存器被修改，或者 key 是新建的时返回 true
+// key 对应的值不是合法的 HyperLogLog 时返回 hll.ErrInvalidHLL
+func (db *KvDB) PFAdd(key []byte, elements ...[]byte) (bool, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return false, err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, String)
+	h, err := db.getHLL(key)
+	if err != nil {
+		return false, err
+	}
+
+	updated := h == nil
+	if h == nil {
+		h = hll.New()
+	}
+	for _, e := range elements {
+		if h.Add(e) {
+			updated = true
+		}
+	}
+
+	if !updated {
+		return false, nil
+	}
+	return true, db.setHLL(key, h)
+}
+
+// PFCount 返回 HyperLogLog 估计的基数，不存在的 key 视为空集
+// 指定多个 key 时返回它们并集的估计基数
+// 只有一个 key 时，会将计算出的基数缓存到 HyperLogLog 中，后续未修改时直接返回缓存的值
+func (db *KvDB) PFCount(keys ...[]byte) (uint64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	for _, key := range keys {
+		if err := db.checkKeyValue(key, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(keys) == 1 {
+		return db.pfCountOne(keys[0])
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	merged := hll.New()
+	for _, key := range keys {
+		h, err := db.getHLL(key)
+		if err != nil {
+			return 0, err
+		}
+		if h != nil {
+			merged.Merge(h)
+		}
+	}
+	return merged.Count(), nil
+}
+
+func (db *KvDB) pfCountOne(key []byte) (uint64, error) {
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, String)
+	h, err := db.getHLL(key)
+	if err != nil || h == nil {
+		return 0, err
+	}
+
+	if h.CacheValid() {
+		return h.Count(), nil
+	}
+	card := h.Count()
+	return card, db.setHLL(key, h)
+}
+
+// PFMerge 将多个 HyperLogLog 合并到 destKey 中，destKey 已存在时也参与合并，不存在的 key 视为空集
+// 合并的结果使用 dense 编码
+func (db *KvDB) PFMerge(destKey []byte, keys ...[]byte) error {
+	if err := db.checkKeyValue(destKey, nil); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := db.checkKeyValue(key, nil); err != nil {
+			return err
+		}
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.expireIfNeeded(destKey, String)
+	dest, err := db.getHLL(destKey)
+	if err != nil {
+		return err
+	}
+	if dest == nil {
+		dest = hll.New()
+	}
+
+	var others []*hll.HyperLogLog
+	for _, key := range keys {
+		db.expireIfNeeded(key, String)
+		h, err := db.getHLL(key)
+		if err != nil {
+			return err
+		}
+		if h != nil {
+			others = append(others, h)
+		}
+	}
+
+	dest.Merge(others...)
+	return db.setHLL(destKey, dest)
+}
+
+// 读取并解析 key 对应的 HyperLogLog，key 不存在时返回 nil，调用方需持有 strIndex 的锁
+func (db *KvDB) getHLL(key []byte) (*hll.HyperLogLog, error) {
+	val, err := db.getValue(key)
+	if err == ErrKeyNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hll.Decode(val)
+}
+
+// 写入 HyperLogLog，保留 key 原有的过期时间，调用方需持有 strIndex 的写锁
+func (db *KvDB) setHLL(key []byte, h *hll.HyperLogLog) error {
+	val := h.Bytes()
+	if err := db.checkKeyValue(key, val); err != nil {
+		return err
+	}
+	return db.setValue(key, val)
+}
@@ -0,0 +1,177 @@
+package KV_Storage
+
+import (
+	"KV_Storage/ds/jsondoc"
+	"KV_Storage/storage"
+	"encoding/json"
+	"sync"
+)
+
+// JSON文档相关操作接口
+// 对文档的局部修改只记录被修改的路径及其新值，而不会重新写入整个文档
+
+// JSONIdx the json idx
+type JSONIdx struct {
+	mu      sync.RWMutex
+	indexes *jsondoc.JSON
+}
+
+func newJSONIdx() *JSONIdx {
+	return &JSONIdx{indexes: jsondoc.New()}
+}
+
+// JSONSet 将文档 key 中 path 处的值设置为 value，value 必须是合法的JSON
+// 文档不存在时 path 必须为根，对象中不存在的成员会被新建，数组只能替换已有的元素
+// nx 为 true 时只在 path 处的值不存在时设置，xx 为 true 时只在其存在时设置，未设置时返回 false
+func (db *KvDB) JSONSet(key []byte, path string, value []byte, nx, xx bool) (bool, error) {
+	if err := db.checkKeyValue(key, value); err != nil {
+		return false, err
+	}
+	p, err := jsondoc.ParsePath(path)
+	if err != nil {
+		return false, err
+	}
+	v, err := jsondoc.Unmarshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	db.jsonIndex.mu.Lock()
+	defer db.jsonIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, JSON)
+
+	k := string(key)
+	_, exist := db.jsonIndex.indexes.Get(k, p)
+	if (nx && exist) || (xx && !exist) {
+		return false, nil
+	}
+	if err = db.jsonIndex.indexes.CanSet(k, p); err != nil {
+		return false, err
+	}
+
+	if err = db.jsonSet(key, path, p, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// JSONGet 返回文档 key 中 path 处的值编码后的JSON，不指定 path 时返回整个文档，文档不存在时返回 nil
+// 指定多个 path 时返回一个以各个 path 为成员名的对象，任一 path 不存在时返回 jsondoc.ErrPathNotExist
+func (db *KvDB) JSONGet(key []byte, paths ...string) ([]byte, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		paths = []string{"$"}
+	}
+	parsed := make([]jsondoc.Path, len(paths))
+	for i, path := range paths {
+		p, err := jsondoc.ParsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = p
+	}
+
+	db.jsonIndex.mu.RLock()
+	defer db.jsonIndex.mu.RUnlock()
+
+	k := string(key)
+	if db.isExpired(key, JSON) || !db.jsonIndex.indexes.Exists(k) {
+		return nil, nil
+	}
+
+	values := make(map[string]interface{})
+	for i, p := range parsed {
+		v, ok := db.jsonIndex.indexes.Get(k, p)
+		if !ok {
+			return nil, jsondoc.ErrPathNotExist
+		}
+		if len(paths) == 1 {
+			return jsondoc.Marshal(v), nil
+		}
+		values[paths[i]] = v
+	}
+	return jsondoc.Marshal(values), nil
+}
+
+// JSONDel 删除文档 key 中 path 处的值，path 为空或为根时删除整个文档，返回被删除的值的个数
+func (db *KvDB) JSONDel(key []byte, path string) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	if path == "" {
+		path = "$"
+	}
+	p, err := jsondoc.ParsePath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	db.jsonIndex.mu.Lock()
+	defer db.jsonIndex.mu.Unlock()
+
+	if len(p) == 0 {
+		ok, err := db.deleteKey(key, JSON)
+		if !ok {
+			return 0, err
+		}
+		return 1, err
+	}
+
+	db.expireIfNeeded(key, JSON)
+	if _, exist := db.jsonIndex.indexes.Get(string(key), p); !exist {
+		return 0, nil
+	}
+
+	e := storage.NewEntry(key, nil, []byte(path), JSON, JSONDel)
+	if err = db.store(e); err != nil {
+		return 0, err
+	}
+	db.jsonIndex.indexes.Del(string(key), p)
+	return 1, nil
+}
+
+// JSONNumIncrBy 将文档 key 中 path 处的数字加上 increment，返回相加之后的值
+// 两者都是整数且结果不溢出时结果为整数，否则为浮点数，path 处的值不是数字时返回 jsondoc.ErrNotNumber
+func (db *KvDB) JSONNumIncrBy(key []byte, path string, increment string) (string, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return "", err
+	}
+	p, err := jsondoc.ParsePath(path)
+	if err != nil {
+		return "", err
+	}
+	by, err := jsondoc.Unmarshal([]byte(increment))
+	if err != nil {
+		return "", jsondoc.ErrNotNumber
+	}
+	num, ok := by.(json.Number)
+	if !ok {
+		return "", jsondoc.ErrNotNumber
+	}
+
+	db.jsonIndex.mu.Lock()
+	defer db.jsonIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, JSON)
+
+	res, err := db.jsonIndex.indexes.NumIncrBy(string(key), p, num)
+	if err != nil {
+		return "", err
+	}
+	if err = db.jsonSet(key, path, p, res); err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
+
+// 记录并设置文档 key 中 path 处的值，调用方需持有 jsonIndex 的写锁并已检查过能否设置
+func (db *KvDB) jsonSet(key []byte, path string, p jsondoc.Path, value interface{}) error {
+	e := storage.NewEntry(key, jsondoc.Marshal(value), []byte(path), JSON, JSONSet)
+	if err := db.store(e); err != nil {
+		return err
+	}
+	return db.jsonIndex.indexes.Set(string(key), p, value)
+}
@@ -0,0 +1,541 @@
+package KV_Storage
+
+import (
+	"KV_Storage/ds/hash"
+	"KV_Storage/ds/jsondoc"
+	"KV_Storage/ds/list"
+	"KV_Storage/ds/set"
+	"KV_Storage/ds/stream"
+	"KV_Storage/ds/zset"
+	"KV_Storage/index"
+	"KV_Storage/storage"
+	"KV_Storage/utils"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sort"
+)
+
+var ErrInvalidCursor = errors.New("kvdb: invalid cursor")
+
+// DefaultScanCount Scan 系列方法在 count 不大于0时每次迭代的元素个数
+const DefaultScanCount = 10
+
+// 各数据类型在 TYPE 命令中的名称
+var dataTypeNames = map[DataType]string{
+	String: "string",
+	List:   "list",
+	Hash:   "hash",
+	Set:    "set",
+	ZSet:   "zset",
+	Stream: "stream",
+	JSON:   "json",
+}
+
+// Del 删除一个或多个key，不论其数据类型，返回被删除的key的个数
+// 不同数据类型的key相互独立，同名的key在各个类型中都会被删除
+func (db *KvDB) Del(keys ...[]byte) (count int, err error) {
+	for _, key := range keys {
+		if err = db.checkKeyValue(key, nil); err != nil {
+			return
+		}
+
+		removed := false
+		for dataType := String; dataType < dataTypeNum; dataType++ {
+			mu := db.idxLock(dataType)
+			mu.Lock()
+			ok, err := db.deleteKey(key, dataType)
+			mu.Unlock()
+
+			if err != nil {
+				return count, err
+			}
+			removed = removed || ok
+		}
+
+		if removed {
+			count++
+		}
+	}
+	return
+}
+
+// Exists 返回给定的key中存在的个数，重复的key会被重复计数
+func (db *KvDB) Exists(keys ...[]byte) (count int) {
+	for _, key := range keys {
+		if db.Type(key) != "none" {
+			count++
+		}
+	}
+	return
+}
+
+// Type 返回key的数据类型：string、list、hash、set、zset、stream、json，key不存在时返回none
+// 同名的key存在于多个类型中时，按照数据类型的定义顺序返回第一个
+func (db *KvDB) Type(key []byte) string {
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		mu := db.idxLock(dataType)
+		mu.RLock()
+		alive := !db.isExpired(key, dataType) && db.keyExists(key, dataType)
+		mu.RUnlock()
+
+		if alive {
+			return dataTypeNames[dataType]
+		}
+	}
+	return "none"
+}
+
+// Keys 返回所有匹配 glob 风格的 pattern 的key（去重并按字典序排列），规则与 Redis 的 KEYS 命令一致
+func (db *KvDB) Keys(pattern []byte) [][]byte {
+	return db.matchKeys(func(key []byte) bool {
+		return utils.GlobMatch(pattern, key)
+	})
+}
+
+// DBSize 返回数据库中key的个数，同名的key只计算一次
+// 累加每种类型中key的个数，再减去已过期的key以及在之前的类型中已经计算过的同名key，不需要收集和排序全部的key
+func (db *KvDB) DBSize() int {
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		mu := db.idxLock(dataType)
+		mu.RLock()
+		defer mu.RUnlock()
+	}
+
+	counted := 0
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		n := db.keyCount(dataType)
+		for key := range db.expires[dataType] {
+			if k := []byte(key); db.isExpired(k, dataType) && db.keyExists(k, dataType) {
+				n--
+			}
+		}
+
+		// 之前的类型中没有key时不会有重复
+		if counted > 0 {
+			db.scanTypeKeys(dataType, "", false, func(key string) bool {
+				if k := []byte(key); !db.isExpired(k, dataType) && db.aliveBefore(k, dataType) {
+					n--
+				}
+				return true
+			})
+		}
+		counted += n
+	}
+	return counted
+}
+
+// Rename 将key改名为newKey，key的数据和过期时间都会转移到newKey下
+// newKey已经存在时，其原有的数据会被覆盖；key不存在时返回 ErrKeyNotExist
+// 每种类型中的转移各自是一条日志，而删除newKey原有的数据在此之前单独记录，
+// 因此崩溃时可能出现newKey已被删除而key尚未改名的情况，但不会出现只转移了部分数据的key
+func (db *KvDB) Rename(key, newKey []byte) error {
+	_, err := db.rename(key, newKey, false)
+	return err
+}
+
+// RenameNX 仅当newKey不存在时，将key改名为newKey，返回是否执行了改名
+func (db *KvDB) RenameNX(key, newKey []byte) (bool, error) {
+	return db.rename(key, newKey, true)
+}
+
+// FlushDB 清空数据库中所有的数据，包括索引、过期字典以及磁盘上的数据文件
+func (db *KvDB) FlushDB() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.lockAll()
+	defer db.unlockAll()
+
+	config := db.config
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		for id, df := range db.archFiles[dataType] {
+			if err := df.Remove(config.DirPath, dataType); err != nil {
+				return err
+			}
+			delete(db.archFiles[dataType], id)
+		}
+
+		if err := db.activeFile[dataType].Remove(config.DirPath, dataType); err != nil {
+			return err
+		}
+		df, err := storage.NewDBFile(config.DirPath, 0, config.RwMethod, config.BlockSize, dataType)
+		if err != nil {
+			return err
+		}
+		db.activeFile[dataType] = df
+		db.activeFileIds[dataType] = 0
+		db.meta.ActiveWriteOff[dataType] = 0
+
+		// 旧的过期信息不能留在磁盘上，否则会在重启后作用到新写入的同名key
+		expires := make(storage.Expires)
+		if err := expires.SaveExpires(expireFilePath(config.DirPath, dataType)); err != nil {
+			return err
+		}
+		db.expires[dataType] = expires
+	}
+
+	db.strIndex.idxList = index.NewSkipList()
+	db.listIndex.indexes = list.New()
+	db.hashIndex.indexes = hash.New()
+	db.setIndex.indexes = set.New()
+	db.zsetIndex.indexes = zset.New()
+	db.streamIndex.indexes = stream.New()
+	db.jsonIndex.indexes = jsondoc.New()
+
+	if db.cache != nil {
+		db.cache.Purge()
+	}
+	return db.saveMeta()
+}
+
+// Scan 基于游标迭代数据库中的key，cursor 为 "0" 时从头开始，返回的游标为 "0" 时表示迭代结束
+// count 为每次迭代检查的key的个数，pattern 在此之后过滤，因此返回的key可能少于 count 个，pattern 为空时不过滤
+// 迭代按照key的字典序进行，迭代期间一直存在的key一定会被返回，且只会被返回一次
+func (db *KvDB) Scan(cursor string, pattern []byte, count int) (next string, keys [][]byte, err error) {
+	sc, err := newScanCursor(cursor, count)
+	if err != nil {
+		return
+	}
+
+	// 每种类型各自取出游标之后未过期的前 count+1 个key，合并后的前 count+1 个即为所有类型中的前 count+1 个
+	seen := make(map[string]struct{})
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		n := 0
+		mu := db.idxLock(dataType)
+		mu.RLock()
+		db.scanTypeKeys(dataType, sc.after, sc.bounded, func(key string) bool {
+			if db.isExpired([]byte(key), dataType) {
+				return true
+			}
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				sc.items = append(sc.items, key)
+			}
+			n++
+			return n <= sc.count
+		})
+		mu.RUnlock()
+	}
+	sort.Strings(sc.items)
+
+	next, page := sc.page()
+	for _, k := range page {
+		key := []byte(k)
+		if len(pattern) == 0 || utils.GlobMatch(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
+
+// 按字典序依次将指定类型中游标之后的key交给 fn，直到 fn 返回 false，调用方需持有相应类型的锁
+func (db *KvDB) scanTypeKeys(dataType DataType, after string, bounded bool, fn func(key string) bool) {
+	switch dataType {
+	case String:
+		node := db.strIndex.idxList.Front()
+		if bounded {
+			// Seek 定位到第一个不小于游标的key，游标本身需要跳过
+			if node = db.strIndex.idxList.Seek([]byte(after)); node != nil && string(node.Key()) == after {
+				node = node.Next()
+			}
+		}
+		for ; node != nil && fn(string(node.Key())); node = node.Next() {
+		}
+	case List:
+		db.listIndex.indexes.ScanKeys(after, bounded, fn)
+	case Hash:
+		db.hashIndex.indexes.ScanKeys(after, bounded, fn)
+	case Set:
+		db.setIndex.indexes.ScanKeys(after, bounded, fn)
+	case ZSet:
+		db.zsetIndex.indexes.ScanKeys(after, bounded, fn)
+	case Stream:
+		db.streamIndex.indexes.ScanKeys(after, bounded, fn)
+	case JSON:
+		db.jsonIndex.indexes.ScanKeys(after, bounded, fn)
+	}
+}
+
+// 一次基于游标的迭代，从上一次返回的最后一个元素之后按字典序收集元素
+type scanCursor struct {
+	after   string
+	bounded bool // 为 false 时从头开始迭代
+	count   int
+	items   []string
+}
+
+// 解析游标，游标由 1 和上一次返回的最后一个元素的十六进制编码组成，"0" 表示从头开始
+func newScanCursor(cursor string, count int) (*scanCursor, error) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	sc := &scanCursor{count: count}
+
+	if cursor == "0" {
+		return sc, nil
+	}
+	if len(cursor) == 0 || cursor[0] != '1' {
+		return nil, ErrInvalidCursor
+	}
+	after, err := hex.DecodeString(cursor[1:])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sc.after, sc.bounded = string(after), true
+	return sc, nil
+}
+
+// 按顺序收集一个元素，多收集一个用于判断迭代是否已经结束，收集满 count+1 个时返回 false
+func (sc *scanCursor) add(item string) bool {
+	sc.items = append(sc.items, item)
+	return len(sc.items) <= sc.count
+}
+
+// 取出本次迭代的元素以及下一次迭代的游标，没有更多元素时游标为 "0"
+func (sc *scanCursor) page() (next string, page []string) {
+	if len(sc.items) <= sc.count {
+		return "0", sc.items
+	}
+	page = sc.items[:sc.count]
+	return "1" + hex.EncodeToString([]byte(page[sc.count-1])), page
+}
+
+func (db *KvDB) rename(key, newKey []byte, nx bool) (ok bool, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	if err = db.checkKeyValue(newKey, nil); err != nil {
+		return
+	}
+
+	// 改名涉及多个数据类型，需要同时持有所有类型的锁
+	db.lockAll()
+	defer db.unlockAll()
+
+	if !db.aliveInAnyType(key) {
+		return false, ErrKeyNotExist
+	}
+	if bytes.Equal(key, newKey) {
+		return !nx, nil
+	}
+	if nx && db.aliveInAnyType(newKey) {
+		return false, nil
+	}
+
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		if _, err = db.deleteKey(newKey, dataType); err != nil {
+			return
+		}
+	}
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		if db.expireIfNeeded(key, dataType) || !db.keyExists(key, dataType) {
+			continue
+		}
+		if err = db.moveKey(key, newKey, dataType); err != nil {
+			return
+		}
+	}
+	return true, nil
+}
+
+// 将指定类型中key的全部数据连同过期时间转移到newKey下，调用方需持有相应类型的写锁，且newKey在该类型中不存在
+// 每种类型的改名只写入一条entry，重放时一步完成，因此崩溃后key的数据要么仍在key下，要么已经完整地转移到newKey下
+func (db *KvDB) moveKey(key, newKey []byte, dataType DataType) error {
+	var e *storage.Entry
+	if dataType == String {
+		// 字符串的value保存在entry中，改名时连同value写入newKey，旧的key记录在extra中
+		value, err := db.getValue(key)
+		if err != nil {
+			return err
+		}
+		e = storage.NewEntry(newKey, value, key, String, StringRename)
+	} else {
+		e = storage.NewEntryNoExtra(key, newKey, dataType, renameMark(dataType))
+	}
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	meta := e.Meta
+	if dataType == String {
+		// 与 setValue 相同，字符串的value只在 KeyValueRamMode 下由 buildIndex 放入索引
+		meta = &storage.Meta{KeySize: e.Meta.KeySize, Key: e.Meta.Key, ExtraSize: e.Meta.ExtraSize, Extra: e.Meta.Extra}
+	}
+	idx := &index.Indexer{
+		Meta:      meta,
+		FileId:    db.activeFileIds[dataType],
+		EntrySize: e.Size(),
+		Offset:    db.activeFile[dataType].Offset - int64(e.Size()),
+	}
+	if err := db.buildIndex(e, idx); err != nil {
+		return err
+	}
+
+	if dataType == List {
+		db.signalListKeys(newKey)
+	}
+	return nil
+}
+
+// 将key的过期时间转移到newKey下，key没有过期时间时清除newKey的过期时间，用于执行和重放改名操作
+func (db *KvDB) renameExpire(key, newKey string, dataType DataType) {
+	if deadline, exist := db.expires[dataType][key]; exist {
+		db.expires[dataType][newKey] = deadline
+		delete(db.expires[dataType], key)
+		return
+	}
+	delete(db.expires[dataType], newKey)
+}
+
+// 返回每种数据类型对应的改名操作标识
+func renameMark(dataType DataType) uint16 {
+	switch dataType {
+	case String:
+		return StringRename
+	case List:
+		return ListRename
+	case Hash:
+		return HashRename
+	case Set:
+		return SetRename
+	case ZSet:
+		return ZSetRename
+	case Stream:
+		return StreamRename
+	case JSON:
+		return JSONRename
+	}
+	return math.MaxUint16
+}
+
+// 返回一条 entry 涉及的全部 key，用于构建数据文件的布隆过滤器
+// 字符串改名时旧的key在 extra 中，其余类型改名、LMOVE 时目标key在 value 中，SMOVE 时目标集合在 extra 中
+func entryKeys(e *storage.Entry) [][]byte {
+	keys := [][]byte{e.Meta.Key}
+	switch {
+	case e.Type == String && e.Mark == StringRename,
+		e.Type == Set && e.Mark == SetSMove:
+		keys = append(keys, e.Meta.Extra)
+	case e.Type != String && e.Mark == renameMark(e.Type),
+		e.Type == List && e.Mark == ListLMove:
+		keys = append(keys, e.Meta.Value)
+	}
+	return keys
+}
+
+// 删除指定类型中未过期的key及其过期时间，返回key是否存在，调用方需持有相应类型的写锁
+func (db *KvDB) deleteKey(key []byte, dataType DataType) (bool, error) {
+	if db.expireIfNeeded(key, dataType) || !db.keyExists(key, dataType) {
+		return false, nil
+	}
+
+	if err := db.removeKey(key, dataType); err != nil {
+		return true, err
+	}
+	delete(db.expires[dataType], string(key))
+	return true, nil
+}
+
+// 判断key是否在任意一个数据类型中存在且未过期，调用方需持有所有类型的锁
+func (db *KvDB) aliveInAnyType(key []byte) bool {
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		if !db.isExpired(key, dataType) && db.keyExists(key, dataType) {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回所有类型中满足 match 的未过期的key（去重并按字典序排列），match 为 nil 时返回所有key
+func (db *KvDB) matchKeys(match func(key []byte) bool) (keys [][]byte) {
+	seen := make(map[string]struct{})
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		mu := db.idxLock(dataType)
+		mu.RLock()
+		for _, k := range db.typeKeys(dataType) {
+			key := []byte(k)
+			if _, ok := seen[k]; ok || db.isExpired(key, dataType) {
+				continue
+			}
+			if match == nil || match(key) {
+				seen[k] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+		mu.RUnlock()
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return
+}
+
+// 返回指定类型中key的个数（包括已过期但尚未删除的），调用方需持有相应类型的锁
+func (db *KvDB) keyCount(dataType DataType) int {
+	switch dataType {
+	case String:
+		return db.strIndex.idxList.Len
+	case List:
+		return db.listIndex.indexes.KeyCount()
+	case Hash:
+		return db.hashIndex.indexes.KeyCount()
+	case Set:
+		return db.setIndex.indexes.KeyCount()
+	case ZSet:
+		return db.zsetIndex.indexes.KeyCount()
+	case Stream:
+		return db.streamIndex.indexes.KeyCount()
+	case JSON:
+		return db.jsonIndex.indexes.KeyCount()
+	}
+	return 0
+}
+
+// 判断key是否存在于 dataType 之前的某个类型中且未过期，调用方需持有这些类型的锁
+func (db *KvDB) aliveBefore(key []byte, dataType DataType) bool {
+	for t := String; t < dataType; t++ {
+		if !db.isExpired(key, t) && db.keyExists(key, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回指定类型中所有的key（包括已过期但尚未删除的），调用方需持有相应类型的锁
+func (db *KvDB) typeKeys(dataType DataType) (keys []string) {
+	switch dataType {
+	case String:
+		db.strIndex.idxList.Foreach(func(e *index.Element) bool {
+			keys = append(keys, string(e.Key()))
+			return true
+		})
+	case List:
+		keys = db.listIndex.indexes.Keys()
+	case Hash:
+		keys = db.hashIndex.indexes.Keys()
+	case Set:
+		keys = db.setIndex.indexes.Keys()
+	case ZSet:
+		keys = db.zsetIndex.indexes.Keys()
+	case Stream:
+		keys = db.streamIndex.indexes.Keys()
+	case JSON:
+		keys = db.jsonIndex.indexes.Keys()
+	}
+	return
+}
+
+// 按照数据类型的定义顺序获取所有类型的写锁，需要同时操作多个类型时使用，固定的加锁顺序可以避免死锁
+func (db *KvDB) lockAll() {
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		db.idxLock(dataType).Lock()
+	}
+}
+
+// 释放 lockAll 获取的所有写锁
+func (db *KvDB) unlockAll() {
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		db.idxLock(dataType).Unlock()
+	}
+}
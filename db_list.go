@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 //---------列表相关操作接口-----------
@@ -16,12 +17,22 @@ import (
 type ListIdx struct {
 	mu      sync.RWMutex
 	indexes *list.List
+	waiters map[string][]*listWaiter // 阻塞在每个列表上的客户端，按阻塞的先后排列
+	closed  bool                     // 数据库已关闭，不再接受新的阻塞操作
 }
 
 func newListIdx() *ListIdx {
-	return &ListIdx{indexes: list.New()}
+	return &ListIdx{indexes: list.New(), waiters: make(map[string][]*listWaiter)}
 }
 
+// ListDirection 列表的一端，用于 LMove 等操作
+type ListDirection uint8
+
+const (
+	ListLeft ListDirection = iota
+	ListRight
+)
+
 // LPush 在列表的头部添加元素，返回添加后的列表长度
 func (db *KvDB) LPush(key []byte, values ...[]byte) (res int, err error) {
 	if err = db.checkKeyValue(key, values...); err != nil {
@@ -31,6 +42,8 @@ func (db *KvDB) LPush(key []byte, values ...[]byte) (res int, err error) {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, List)
+
 	for _, val := range values {
 		e := storage.NewEntryNoExtra(key, val, List, ListLPush) // 构建相应操作的entry
 
@@ -40,6 +53,7 @@ func (db *KvDB) LPush(key []byte, values ...[]byte) (res int, err error) {
 		res = db.listIndex.indexes.LPush(string(key), val)
 	}
 
+	db.signalListKeys(key)
 	return
 }
 
@@ -52,6 +66,8 @@ func (db *KvDB) RPush(key []byte, values ...[]byte) (res int, err error) {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, List)
+
 	for _, val := range values {
 		e := storage.NewEntryNoExtra(key, val, List, ListRPush)
 		if err = db.store(e); err != nil {
@@ -60,6 +76,7 @@ func (db *KvDB) RPush(key []byte, values ...[]byte) (res int, err error) {
 		res = db.listIndex.indexes.RPush(string(key), val)
 	}
 
+	db.signalListKeys(key)
 	return
 }
 
@@ -73,6 +90,9 @@ func (db *KvDB) LPop(key []byte) ([]byte, error) {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, List)
+	defer db.clearExpireIfEmpty(key, List)
+
 	val := db.listIndex.indexes.LPop(string(key))
 
 	if val != nil {
@@ -95,6 +115,9 @@ func (db *KvDB) RPop(key []byte) ([]byte, error) {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, List)
+	defer db.clearExpireIfEmpty(key, List)
+
 	val := db.listIndex.indexes.RPop(string(key))
 
 	if val != nil {
@@ -107,6 +130,119 @@ func (db *KvDB) RPop(key []byte) ([]byte, error) {
 	return val, nil
 }
 
+// LPushX 只在列表 key 存在时在其头部添加元素，返回添加后的列表长度，列表不存在时返回0
+func (db *KvDB) LPushX(key []byte, values ...[]byte) (int, error) {
+	return db.pushExisting(key, values, true)
+}
+
+// RPushX 只在列表 key 存在时在其尾部添加元素，返回添加后的列表长度，列表不存在时返回0
+func (db *KvDB) RPushX(key []byte, values ...[]byte) (int, error) {
+	return db.pushExisting(key, values, false)
+}
+
+// for LPushX and RPushX
+func (db *KvDB) pushExisting(key []byte, values [][]byte, front bool) (res int, err error) {
+	if err = db.checkKeyValue(key, values...); err != nil {
+		return
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, List)
+	if db.listIndex.indexes.LLen(string(key)) == 0 {
+		return
+	}
+
+	for _, val := range values {
+		if front {
+			e := storage.NewEntryNoExtra(key, val, List, ListLPush)
+			if err = db.store(e); err != nil {
+				return
+			}
+			res = db.listIndex.indexes.LPush(string(key), val)
+		} else {
+			e := storage.NewEntryNoExtra(key, val, List, ListRPush)
+			if err = db.store(e); err != nil {
+				return
+			}
+			res = db.listIndex.indexes.RPush(string(key), val)
+		}
+	}
+	return
+}
+
+// LMove 从列表 src 的 from 端取出元素并加入列表 dst 的 to 端，返回被移动的元素，src 为空时返回 nil
+// 取出和加入作为一条日志原子地记录，不会因为两者之间发生崩溃而丢失元素，src 和 dst 可以是同一个列表
+func (db *KvDB) LMove(src, dst []byte, from, to ListDirection) ([]byte, error) {
+	if err := db.checkKeyValue(src, nil); err != nil {
+		return nil, err
+	}
+	if err := db.checkKeyValue(dst, nil); err != nil {
+		return nil, err
+	}
+
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	db.expireIfNeeded(src, List)
+	if db.listIndex.indexes.LLen(string(src)) == 0 {
+		return nil, nil
+	}
+
+	val, err := db.listPopOrMove(src, dst, from, to)
+	if err != nil {
+		return nil, err
+	}
+	db.signalListKeys(dst)
+	return val, nil
+}
+
+// RPopLPush 取出列表 src 尾部的元素并加入列表 dst 的头部，等同于 LMove(src, dst, ListRight, ListLeft)
+func (db *KvDB) RPopLPush(src, dst []byte) ([]byte, error) {
+	return db.LMove(src, dst, ListRight, ListLeft)
+}
+
+// LPos 返回列表 key 中与 val 相等的元素的下标，没有匹配的元素时返回空
+// rank 为正数时从表头开始查找并返回第 rank 个及之后的匹配，为负数时从表尾开始查找，不能为0
+// count 为0时返回所有匹配的下标，否则最多返回 count 个；maxLen 大于0时最多比较 maxLen 个元素
+func (db *KvDB) LPos(key, val []byte, rank, count, maxLen int) ([]int, error) {
+	if err := db.checkKeyValue(key, val); err != nil {
+		return nil, err
+	}
+	if rank == 0 || count < 0 || maxLen < 0 {
+		return nil, ErrInvalidLPosArgs
+	}
+
+	db.listIndex.mu.RLock()
+	defer db.listIndex.mu.RUnlock()
+
+	if db.isExpired(key, List) {
+		return nil, nil
+	}
+
+	return db.listIndex.indexes.LPos(string(key), val, rank, count, maxLen), nil
+}
+
+// BLPop LPop 的阻塞版本，依次检查 keys 中的列表，从第一个非空的列表头部取出元素，返回该列表的key和取出的元素
+// 所有列表都为空时阻塞，直到其中任一列表加入了元素或超过 timeout，timeout 为0时一直阻塞
+// 多个客户端阻塞在同一个列表上时，按阻塞的先后顺序依次得到元素，超时返回的 key 为 nil
+func (db *KvDB) BLPop(timeout time.Duration, keys ...[]byte) (key, val []byte, err error) {
+	return db.blockingListPop(keys, nil, ListLeft, ListLeft, timeout)
+}
+
+// BRPop BLPop 的尾部版本，从第一个非空的列表尾部取出元素
+func (db *KvDB) BRPop(timeout time.Duration, keys ...[]byte) (key, val []byte, err error) {
+	return db.blockingListPop(keys, nil, ListRight, ListRight, timeout)
+}
+
+// BLMove 从列表 src 的 from 端取出元素并加入列表 dst 的 to 端，两者作为一条日志原子地记录，返回被移动的元素
+// src 为空时阻塞，直到其加入了元素或超过 timeout，timeout 为0时一直阻塞，超时返回 nil
+func (db *KvDB) BLMove(src, dst []byte, from, to ListDirection, timeout time.Duration) ([]byte, error) {
+	_, val, err := db.blockingListPop([][]byte{src}, dst, from, to, timeout)
+	return val, err
+}
+
 // LIndex 返回列表在index处的值，如果不存在则返回nil
 func (db *KvDB) LIndex(key []byte, idx int) []byte {
 
@@ -117,6 +253,10 @@ func (db *KvDB) LIndex(key []byte, idx int) []byte {
 	db.listIndex.mu.RLock()
 	defer db.listIndex.mu.RUnlock()
 
+	if db.isExpired(key, List) {
+		return nil
+	}
+
 	return db.listIndex.indexes.LIndex(string(key), idx)
 }
 
@@ -134,6 +274,9 @@ func (db *KvDB) LRem(key, value []byte, count int) (int, error) {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, List)
+	defer db.clearExpireIfEmpty(key, List)
+
 	res := db.listIndex.indexes.LRem(string(key), value, count)
 
 	if res > 0 {
@@ -162,6 +305,8 @@ func (db *KvDB) LInsert(key string, option list.InsertOption, pivot, val []byte)
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
+	db.expireIfNeeded([]byte(key), List)
+
 	count = db.listIndex.indexes.LInsert(key, option, pivot, val)
 	if count != -1 {
 		var buf bytes.Buffer
@@ -174,13 +319,14 @@ func (db *KvDB) LInsert(key string, option list.InsertOption, pivot, val []byte)
 		if err = db.store(e); err != nil {
 			return
 		}
+		db.signalListKeys([]byte(key))
 	}
 
 	return
 }
 
-// LSet 将列表 key 下标为 index 的元素的值设置为 val
-// bool返回值表示操作是否成功
+// LSet 将列表 key 下标为 index 的元素的值设置为 val，index 为负数时从表尾开始计算
+// bool返回值表示操作是否成功，下标不存在时不会写入日志
 func (db *KvDB) LSet(key []byte, idx int, val []byte) (bool, error) {
 
 	if err := db.checkKeyValue(key, val); err != nil {
@@ -190,6 +336,17 @@ func (db *KvDB) LSet(key []byte, idx int, val []byte) (bool, error) {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, List)
+
+	// 日志中只记录转换后的非负下标，重放时会忽略旧版本写入的负数下标
+	length := db.listIndex.indexes.LLen(string(key))
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return false, nil
+	}
+
 	i := strconv.Itoa(idx)
 	e := storage.NewEntry(key, val, []byte(i), List, ListLSet)
 	if err := db.store(e); err != nil {
@@ -210,6 +367,9 @@ func (db *KvDB) LTrim(key []byte, start, end int) error {
 	db.listIndex.mu.Lock()
 	defer db.listIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, List)
+	defer db.clearExpireIfEmpty(key, List)
+
 	if res := db.listIndex.indexes.LTrim(string(key), start, end); res {
 		var buf bytes.Buffer
 		buf.Write([]byte(strconv.Itoa(start)))
@@ -237,6 +397,10 @@ func (db *KvDB) LRange(key []byte, start, end int) ([][]byte, error) {
 	db.listIndex.mu.RLock()
 	defer db.listIndex.mu.RUnlock()
 
+	if db.isExpired(key, List) {
+		return nil, nil
+	}
+
 	return db.listIndex.indexes.LRange(string(key), start, end), nil
 }
 
@@ -250,6 +414,10 @@ func (db *KvDB) LLen(key []byte) int {
 	db.listIndex.mu.RLock()
 	defer db.listIndex.mu.RUnlock()
 
+	if db.isExpired(key, List) {
+		return 0
+	}
+
 	return db.listIndex.indexes.LLen(string(key))
 }
 
@@ -262,6 +430,10 @@ func (db *KvDB) LKeyExists(key []byte) (ok bool) {
 	db.listIndex.mu.RLock()
 	defer db.listIndex.mu.RUnlock()
 
+	if db.isExpired(key, List) {
+		return
+	}
+
 	ok = db.listIndex.indexes.LKeyExists(string(key))
 	return
 }
@@ -275,6 +447,10 @@ func (db *KvDB) LValExists(key []byte, val []byte) (ok bool) {
 	db.listIndex.mu.RLock()
 	defer db.listIndex.mu.RUnlock()
 
+	if db.isExpired(key, List) {
+		return
+	}
+
 	ok = db.listIndex.indexes.LValExists(string(key), val)
 	return
 }
@@ -3,6 +3,7 @@ package KV_Storage
 import (
 	"KV_Storage/ds/set"
 	"KV_Storage/storage"
+	"KV_Storage/utils"
 	"sync"
 )
 
@@ -28,6 +29,8 @@ func (db *KvDB) SAdd(key []byte, members ...[]byte) (res int, err error) {
 	db.setIndex.mu.Lock()
 	defer db.setIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, Set)
+
 	for _, m := range members {
 		exist := db.setIndex.indexes.SIsMember(string(key), m)
 		if !exist {
@@ -52,6 +55,9 @@ func (db *KvDB) SPop(key []byte, count int) (values [][]byte, err error) {
 	db.setIndex.mu.Lock()
 	defer db.setIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, Set)
+	defer db.clearExpireIfEmpty(key, Set)
+
 	values = db.setIndex.indexes.SPop(string(key), count)
 	for _, v := range values {
 		e := storage.NewEntryNoExtra(key, v, Set, SetSRem)
@@ -69,6 +75,10 @@ func (db *KvDB) SIsMember(key, member []byte) bool {
 	db.setIndex.mu.RLock()
 	defer db.setIndex.mu.RUnlock()
 
+	if db.isExpired(key, Set) {
+		return false
+	}
+
 	return db.setIndex.indexes.SIsMember(string(key), member)
 }
 
@@ -81,6 +91,10 @@ func (db *KvDB) SRandMember(key []byte, count int) [][]byte {
 	db.setIndex.mu.RLock()
 	defer db.setIndex.mu.RUnlock()
 
+	if db.isExpired(key, Set) {
+		return nil
+	}
+
 	return db.setIndex.indexes.SRandMember(string(key), count)
 }
 
@@ -95,6 +109,9 @@ func (db *KvDB) SRem(key []byte, members ...[]byte) (res int, err error) {
 	db.setIndex.mu.Lock()
 	defer db.setIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, Set)
+	defer db.clearExpireIfEmpty(key, Set)
+
 	for _, m := range members {
 		if ok := db.setIndex.indexes.SRem(string(key), m); ok {
 			e := storage.NewEntryNoExtra(key, m, Set, SetSRem)
@@ -114,6 +131,10 @@ func (db *KvDB) SMove(src, dst, member []byte) error {
 	db.setIndex.mu.Lock()
 	defer db.setIndex.mu.Unlock()
 
+	db.expireIfNeeded(src, Set)
+	db.expireIfNeeded(dst, Set)
+	defer db.clearExpireIfEmpty(src, Set)
+
 	if ok := db.setIndex.indexes.SMove(string(src), string(dst), member); ok {
 		e := storage.NewEntry(src, member, dst, Set, SetSMove)
 		if err := db.store(e); err != nil {
@@ -134,6 +155,10 @@ func (db *KvDB) SCard(key []byte) int {
 	db.setIndex.mu.RLock()
 	defer db.setIndex.mu.RUnlock()
 
+	if db.isExpired(key, Set) {
+		return 0
+	}
+
 	return db.setIndex.indexes.SCard(string(key))
 }
 
@@ -147,9 +172,41 @@ func (db *KvDB) SMembers(key []byte) (val [][]byte) {
 	db.setIndex.mu.RLock()
 	defer db.setIndex.mu.RUnlock()
 
+	if db.isExpired(key, Set) {
+		return
+	}
+
 	return db.setIndex.indexes.SMembers(string(key))
 }
 
+// SScan 基于游标迭代集合 key 中的元素
+// cursor、pattern 和 count 的含义与 Scan 相同，pattern 用于过滤元素
+func (db *KvDB) SScan(key []byte, cursor string, pattern []byte, count int) (next string, members [][]byte, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	sc, err := newScanCursor(cursor, count)
+	if err != nil {
+		return
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.isExpired(key, Set) {
+		return "0", nil, nil
+	}
+
+	db.setIndex.indexes.SScan(string(key), sc.after, sc.bounded, sc.add)
+	next, page := sc.page()
+	for _, m := range page {
+		if len(pattern) == 0 || utils.GlobMatch(pattern, []byte(m)) {
+			members = append(members, []byte(m))
+		}
+	}
+	return
+}
+
 // SUnion 返回给定全部集合数据的并集
 func (db *KvDB) SUnion(keys ...[]byte) (val [][]byte) {
 
@@ -162,12 +219,153 @@ func (db *KvDB) SUnion(keys ...[]byte) (val [][]byte) {
 
 	var s []string
 	for _, k := range keys {
-		s = append(s, string(k))
+		if !db.isExpired(k, Set) {
+			s = append(s, string(k))
+		}
 	}
 
 	return db.setIndex.indexes.SUnion(s...)
 }
 
+// SInter 返回给定全部集合数据的交集，任一集合不存在或已过期时交集为空
+func (db *KvDB) SInter(keys ...[]byte) (val [][]byte) {
+
+	if len(keys) == 0 {
+		return
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	s, ok := db.aliveSetKeys(keys)
+	if !ok {
+		return
+	}
+	return db.setIndex.indexes.SInter(s...)
+}
+
+// SInterCard 返回给定全部集合数据的交集的元素个数，limit 大于0时计算到 limit 个即返回
+func (db *KvDB) SInterCard(limit int, keys ...[]byte) int {
+
+	if len(keys) == 0 {
+		return 0
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	s, ok := db.aliveSetKeys(keys)
+	if !ok {
+		return 0
+	}
+	return db.setIndex.indexes.SInterCard(limit, s...)
+}
+
+// SMIsMember 依次判断每个 member 元素是不是集合 key 的成员
+func (db *KvDB) SMIsMember(key []byte, members ...[]byte) []bool {
+
+	res := make([]bool, len(members))
+	if err := db.checkKeyValue(key, members...); err != nil {
+		return res
+	}
+
+	db.setIndex.mu.RLock()
+	defer db.setIndex.mu.RUnlock()
+
+	if db.isExpired(key, Set) {
+		return res
+	}
+
+	for i, m := range members {
+		res[i] = db.setIndex.indexes.SIsMember(string(key), m)
+	}
+	return res
+}
+
+// SInterStore 计算给定全部集合数据的交集并保存到集合 dst 中，返回结果集的元素个数
+// dst 原有的元素和过期时间会被覆盖，结果为空时删除 dst，结果能放入一条日志时作为一条日志记录，否则先清空 dst 再逐个记录元素
+func (db *KvDB) SInterStore(dst []byte, keys ...[]byte) (int, error) {
+	return db.setStore(dst, keys, func(s []string) [][]byte {
+		return db.setIndex.indexes.SInter(s...)
+	})
+}
+
+// SUnionStore 计算给定全部集合数据的并集并保存到集合 dst 中，返回结果集的元素个数，其余与 SInterStore 相同
+func (db *KvDB) SUnionStore(dst []byte, keys ...[]byte) (int, error) {
+	return db.setStore(dst, keys, func(s []string) [][]byte {
+		return db.setIndex.indexes.SUnion(s...)
+	})
+}
+
+// SDiffStore 计算第一个集合与其余集合的差集并保存到集合 dst 中，返回结果集的元素个数，其余与 SInterStore 相同
+func (db *KvDB) SDiffStore(dst []byte, keys ...[]byte) (int, error) {
+	return db.setStore(dst, keys, func(s []string) [][]byte {
+		return db.setIndex.indexes.SDiff(s...)
+	})
+}
+
+// for SInterStore, SUnionStore and SDiffStore
+// 已过期的集合会先被删除，因此 compute 中不存在的集合即视为空集
+func (db *KvDB) setStore(dst []byte, keys [][]byte, compute func([]string) [][]byte) (int, error) {
+	if err := db.checkKeyValue(dst, nil); err != nil {
+		return 0, err
+	}
+
+	db.setIndex.mu.Lock()
+	defer db.setIndex.mu.Unlock()
+
+	s := make([]string, len(keys))
+	for i, k := range keys {
+		db.expireIfNeeded(k, Set)
+		s[i] = string(k)
+	}
+	db.expireIfNeeded(dst, Set)
+
+	members := compute(s)
+	if len(members) == 0 && !db.keyExists(dst, Set) {
+		return 0, nil
+	}
+
+	e := storage.NewEntryNoExtra(dst, utils.EncodeValues(members), Set, SetSStore)
+	if db.fitsInEntry(e) {
+		if err := db.store(e); err != nil {
+			return 0, err
+		}
+	} else if err := db.storeSetMembers(dst, members); err != nil {
+		return 0, err
+	}
+	db.setIndex.indexes.SStore(string(dst), members)
+	delete(db.expires[Set], string(dst))
+	return len(members), nil
+}
+
+// 结果集编码后超过单个value或数据文件的大小限制时，先清空 dst 再逐个记录其元素
+// 这种情况下写入的是多条日志，中途崩溃时 dst 只会恢复出部分元素，调用方需持有 setIndex 的写锁
+func (db *KvDB) storeSetMembers(dst []byte, members [][]byte) error {
+	if db.keyExists(dst, Set) {
+		if err := db.store(storage.NewEntryNoExtra(dst, nil, Set, SetSClear)); err != nil {
+			return err
+		}
+	}
+	for _, m := range members {
+		if err := db.store(storage.NewEntryNoExtra(dst, m, Set, SetSAdd)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 返回 keys 中全部集合的key，任一集合已过期时 ok 为 false，调用方需持有 setIndex 的锁
+func (db *KvDB) aliveSetKeys(keys [][]byte) (s []string, ok bool) {
+	for _, k := range keys {
+		if db.isExpired(k, Set) {
+			return nil, false
+		}
+		s = append(s, string(k))
+	}
+	return s, true
+}
+
 // SDiff 返回给定集合数据的差集
 func (db *KvDB) SDiff(keys ...[]byte) (val [][]byte) {
 
@@ -178,9 +376,16 @@ func (db *KvDB) SDiff(keys ...[]byte) (val [][]byte) {
 	db.setIndex.mu.RLock()
 	defer db.setIndex.mu.RUnlock()
 
+	// 第一个集合过期时差集为空，其余过期的集合视为空集
+	if db.isExpired(keys[0], Set) {
+		return
+	}
+
 	var s []string
 	for _, k := range keys {
-		s = append(s, string(k))
+		if !db.isExpired(k, Set) {
+			s = append(s, string(k))
+		}
 	}
 
 	return db.setIndex.indexes.SDiff(s...)
@@ -4,10 +4,11 @@ import (
 	"KV_Storage/index"
 	"KV_Storage/storage"
 	"bytes"
-	"log"
-	"strings"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"strconv"
 	"sync"
-	"time"
 )
 
 // StrIdx string idx
@@ -54,9 +55,53 @@ func (db *KvDB) Set(key, value []byte) error {
 		return err
 	}
 	//清除过期时间
-	db.Persist(key)
+	return db.persist(key, String)
+}
 
-	return nil
+// SetOptions Set 的可选参数
+type SetOptions struct {
+	ExpireAt int64 // 过期时间点（毫秒级 Unix 时间戳），0 表示不设置过期时间
+	NX       bool  // 只在 key 不存在时设置
+	XX       bool  // 只在 key 已存在时设置
+	KeepTTL  bool  // 保留 key 原有的过期时间
+}
+
+// SetWithOptions 按照选项设置 key 的值，对应 SET key value [EX|PX|EXAT|PXAT] [NX|XX] [KEEPTTL]
+// 返回值表示是否执行了设置操作
+func (db *KvDB) SetWithOptions(key, value []byte, opts SetOptions) (ok bool, err error) {
+	if err = db.checkKeyValue(key, value); err != nil {
+		return
+	}
+	if (opts.NX && opts.XX) || (opts.KeepTTL && opts.ExpireAt != 0) {
+		return false, ErrInvalidSetOptions
+	}
+	if opts.ExpireAt < 0 {
+		return false, ErrInvalidTTL
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, String)
+	exist := db.keyExists(key, String)
+	if (opts.NX && exist) || (opts.XX && !exist) {
+		return
+	}
+
+	if err = db.setValue(key, value); err != nil {
+		return
+	}
+	ok = true
+
+	if opts.ExpireAt > 0 {
+		if err = db.setExpire(key, String, uint64(opts.ExpireAt)); err != nil {
+			return
+		}
+		db.expireIfNeeded(key, String) // 过期时间点已过去时直接删除
+	} else if !opts.KeepTTL {
+		err = db.removeExpire(key, String)
+	}
+	return
 }
 
 func (db *KvDB) SetNx(key, value []byte) error {
@@ -72,6 +117,9 @@ func (db *KvDB) Get(key []byte) ([]byte, error) {
 		return nil, ErrEmptyKey
 	}
 
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
 	node := db.strIndex.idxList.Get(key) // 从索引（跳表）中查找
 	if node == nil {
 		return nil, ErrKeyNotExist
@@ -82,11 +130,8 @@ func (db *KvDB) Get(key []byte) ([]byte, error) {
 		return nil, ErrNilIndexer
 	}
 
-	db.strIndex.mu.RLock()
-	defer db.strIndex.mu.RUnlock()
-
-	//判断是否过期
-	if db.expireIfNeeded(key) {
+	//判断是否过期，过期的key由写操作和后台的过期循环删除
+	if db.isExpired(key, String) {
 		return nil, ErrKeyExpired
 	}
 
@@ -97,18 +142,7 @@ func (db *KvDB) Get(key []byte) ([]byte, error) {
 
 	//如果只有key在内存中，那么需要从db file中获取value
 	if db.config.IdxMode == KeyOnlyRamMode {
-		df := db.activeFile[String]
-		if idx.FileId != db.activeFileIds[String] {
-			df = db.archFiles[String][idx.FileId]
-		}
-
-		e, err := df.Read(idx.Offset)
-		if err != nil {
-			return nil, err
-		}
-
-		return e.Meta.Value, nil
-
+		return db.readValue(String, idx)
 	}
 
 	return nil, ErrKeyNotExist
@@ -136,32 +170,27 @@ func (db *KvDB) Append(key, value []byte) error {
 		return err
 	}
 
-	e, err := db.Get(key)
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	// 已过期的key先被删除，之后视为不存在
+	db.expireIfNeeded(key, String)
+	e, err := db.getValue(key)
 	if err != nil && err != ErrKeyNotExist {
 		return err
 	}
 
-	if db.expireIfNeeded(key) {
-		return ErrKeyExpired
-	}
-	appendExist := false
-
 	if e != nil {
-		appendExist = true
-		e = append(e, value...)
+		e = append(e[:len(e):len(e)], value...)
 	} else {
 		e = value
 	}
 
-	if err := db.doSet(key, e); err != nil {
+	// 追加后的value同样不能超过 MaxValueSize
+	if err := db.checkKeyValue(key, e); err != nil {
 		return err
 	}
-
-	if !appendExist {
-		db.Persist(key)
-	}
-
-	return nil
+	return db.setValue(key, e)
 }
 
 // StrLen 返回key存储的字符串值的长度
@@ -176,7 +205,7 @@ func (db *KvDB) StrLen(key []byte) int {
 
 	e := db.strIndex.idxList.Get(key)
 	if e != nil {
-		if db.expireIfNeeded(key) {
+		if db.isExpired(key, String) {
 			return 0
 		}
 		idx := e.Value().(*index.Indexer)
@@ -197,7 +226,7 @@ func (db *KvDB) StrExists(key []byte) bool {
 	defer db.strIndex.mu.RUnlock()
 
 	exist := db.strIndex.idxList.Exist(key)
-	if exist && !db.expireIfNeeded(key) {
+	if exist && !db.isExpired(key, String) {
 		return true
 	}
 	return false
@@ -213,7 +242,7 @@ func (db *KvDB) StrRem(key []byte) error {
 	defer db.strIndex.mu.Unlock()
 
 	if ele := db.strIndex.idxList.Remove(key); ele != nil {
-		delete(db.expires, string(key))
+		delete(db.expires[String], string(key))
 		e := storage.NewEntryNoExtra(key, nil, String, StringRem)
 		if err := db.store(e); err != nil {
 			return err
@@ -223,16 +252,16 @@ func (db *KvDB) StrRem(key []byte) error {
 	return nil
 }
 
-// PrefixScan 根据前缀查找所有匹配的 key 对应的 value
+// PrefixScan 根据前缀查找所有匹配的 key 及其对应的 value
 // 参数 limit 和 offset 控制取数据的范围，类似关系型数据库中的分页操作
 // 如果 limit 为负数，则返回所有满足条件的结果
-func (db *KvDB) PrefixScan(prefix string, limit, offset int) (val [][]byte, err error) {
+func (db *KvDB) PrefixScan(prefix string, limit, offset int) (kvs []KV, err error) {
 
 	if limit == 0 {
 		return
 	}
 
-	if offset < 0 {
+	if offset < 0 || limit < 0 {
 		offset = 0
 	}
 
@@ -240,145 +269,729 @@ func (db *KvDB) PrefixScan(prefix string, limit, offset int) (val [][]byte, err
 	if err = db.checkKeyValue([]byte(prefix), nil); err != nil {
 		return
 	}
-	// 对索引加读锁
+
+	// 满足前缀的 key 位于 [prefix, prefixUpperBound(prefix)) 范围内
+	it := db.NewIterator(IteratorOptions{
+		LowerBound: []byte(prefix),
+		UpperBound: prefixUpperBound([]byte(prefix)),
+	})
+	defer it.Close()
+
+	for ; it.Valid() && limit != 0; it.Next() {
+		if offset > 0 { // 往后偏移offset个满足前缀的key
+			offset--
+			continue
+		}
+
+		var value []byte
+		if value, err = it.Value(); err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, KV{Key: it.Key(), Value: value})
+		if limit > 0 {
+			limit--
+		}
+	}
+	return
+}
+
+// RangeScan 范围扫描，查找 key 从 start 到 end 之间（包含两端）的数据
+func (db *KvDB) RangeScan(start, end []byte) ([]KV, error) {
+	return db.Range(RangeOptions{Start: start, End: end})
+}
+
+// 返回大于所有以 prefix 为前缀的 key 的最小值，不存在时返回 nil
+func prefixUpperBound(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			upper := append([]byte{}, prefix[:i+1]...)
+			upper[i]++
+			return upper
+		}
+	}
+	return nil
+}
+
+// KV 键值对
+type KV struct {
+	Key   []byte
+	Value []byte
+}
+
+// RangeOptions 范围查询的选项
+type RangeOptions struct {
+	Start          []byte // 范围的起点，为空时不限制
+	End            []byte // 范围的终点，为空时不限制
+	StartExclusive bool   // 是否不包含起点
+	EndExclusive   bool   // 是否不包含终点
+	Reverse        bool   // 是否按key从大到小的顺序返回
+	Limit          int    // 最多返回的个数，不大于0时不限制
+}
+
+// Range 按key的顺序返回指定范围内的键值对，已过期的key会被跳过
+func (db *KvDB) Range(opts RangeOptions) (kvs []KV, err error) {
+	iterOpts := IteratorOptions{Reverse: opts.Reverse}
+	if opts.Start != nil {
+		iterOpts.LowerBound = opts.Start
+		if opts.StartExclusive { // 大于 key 的最小的key是 key 后面追加一个0字节
+			iterOpts.LowerBound = append(append([]byte{}, opts.Start...), 0)
+		}
+	}
+	if opts.End != nil {
+		iterOpts.UpperBound = opts.End
+		if !opts.EndExclusive {
+			iterOpts.UpperBound = append(append([]byte{}, opts.End...), 0)
+		}
+	}
+
+	it := db.NewIterator(iterOpts)
+	defer it.Close()
+
+	for ; it.Valid(); it.Next() {
+		if opts.Limit > 0 && len(kvs) >= opts.Limit {
+			break
+		}
+
+		var value []byte
+		if value, err = it.Value(); err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, KV{Key: it.Key(), Value: value})
+	}
+	return
+}
+
+// MGet 返回所有给定 key 的值，不存在或已过期的 key 对应的值为 nil
+func (db *KvDB) MGet(keys ...[]byte) (values [][]byte, err error) {
+	for _, key := range keys {
+		if err = db.checkKeyValue(key, nil); err != nil {
+			return nil, err
+		}
+	}
+
 	db.strIndex.mu.RLock()
 	defer db.strIndex.mu.RUnlock()
-	// 找到第一个和给定前缀匹配的节点
-	e := db.strIndex.idxList.FindPrefix([]byte(prefix))
 
-	if limit > 0 { // 往后偏移offset个满足前缀的key
-		for i := 0; i < offset && e != nil && strings.HasPrefix(string(e.Key()), prefix); i++ {
-			e = e.Next()
+	values = make([][]byte, len(keys))
+	for i, key := range keys {
+		val, err := db.getValue(key)
+		if err != nil && err != ErrKeyNotExist {
+			return nil, err
 		}
+		values[i] = val
 	}
+	return
+}
 
-	for e != nil && strings.HasPrefix(string(e.Key()), prefix) && limit != 0 {
-		item := e.Value().(*index.Indexer) //item为e相应的索引信息
-		var value []byte
+// MSet 同时设置多个键值对，已存在的 key 会被覆盖，并清除其过期时间
+// 所有的键值对在持有 strIndex 写锁期间依次写入，其他读写操作不会看到只写入了一部分的中间状态
+func (db *KvDB) MSet(kvs ...KV) error {
+	for _, kv := range kvs {
+		if err := db.checkKeyValue(kv.Key, kv.Value); err != nil {
+			return err
+		}
+	}
 
-		if db.config.IdxMode == KeyOnlyRamMode { // 如果只有key存在内存
-			value, err = db.Get(e.Key()) // 就去磁盘中相应位置拿到value值
-			if err != nil {
-				return
-			}
-		} else { // 如果键值都在内存，直接从索引信息中拿到value值
-			if item != nil {
-				value = item.Meta.Value
-			}
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	return db.setValues(kvs)
+}
+
+// MSetNX 当且仅当所有给定的 key 都不存在时，同时设置多个键值对，只要有一个 key 已经存在，就不会设置任何值
+// 返回是否执行了设置操作
+func (db *KvDB) MSetNX(kvs ...KV) (bool, error) {
+	for _, kv := range kvs {
+		if err := db.checkKeyValue(kv.Key, kv.Value); err != nil {
+			return false, err
 		}
+	}
 
-		expired := db.expireIfNeeded(e.Key()) // 检查key是否过期
-		if !expired {                         // 如果没有过期就加入到结果集中
-			val = append(val, value)
-			e = e.Next()
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	for _, kv := range kvs {
+		if !db.expireIfNeeded(kv.Key, String) && db.keyExists(kv.Key, String) {
+			return false, nil
 		}
-		if limit > 0 && !expired { // limit减一然后进入下一个循环
-			limit--
+	}
+
+	if err := db.setValues(kvs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 依次写入多个键值对并清除其过期时间，调用方需持有 strIndex 的写锁
+func (db *KvDB) setValues(kvs []KV) error {
+	for _, kv := range kvs {
+		if err := db.setValue(kv.Key, kv.Value); err != nil {
+			return err
+		}
+		if err := db.removeExpire(kv.Key, String); err != nil {
+			return err
 		}
 	}
-	return
+	return nil
 }
 
-// RangeScan 范围扫描，查找 key 从 start 到 end 之间的数据
-func (db *KvDB) RangeScan(start, end []byte) (val [][]byte, err error) {
+// GetRange 返回 key 中字符串值从 start 到 end（包含）的子串，负数表示从末尾开始计算的位置
+// 超出范围的部分会被忽略，key 不存在时返回空
+func (db *KvDB) GetRange(key []byte, start, end int) ([]byte, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
 
-	node := db.strIndex.idxList.Get(start) // 通过跳表的查找接口直接找到start对应的节点
-	if node == nil {                       // 如果节点为空，则返回错误
-		return nil, ErrKeyNotExist
+	val, err := db.getValue(key)
+	if err != nil {
+		if err == ErrKeyNotExist {
+			err = nil
+		}
+		return nil, err
+	}
+
+	length := len(val)
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end {
+		return nil, nil
+	}
+	return val[start : end+1], nil
+}
+
+// SetRange 从偏移量 offset 开始用 value 覆写 key 中的字符串值，超出原有长度的部分以零字节填充
+// key 不存在时视为空字符串，原有的过期时间保持不变，返回修改之后字符串的长度
+func (db *KvDB) SetRange(key []byte, offset int, value []byte) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, ErrInvalidOffset
+	}
+	if uint64(offset)+uint64(len(value)) > uint64(db.config.MaxValueSize) {
+		return 0, ErrValueTooLarge
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, String)
+	val, err := db.getValue(key)
+	if err != nil && err != ErrKeyNotExist {
+		return 0, err
+	}
+	if len(value) == 0 { // 不需要修改，key 不存在时也不会创建
+		return len(val), nil
+	}
+
+	newVal := make([]byte, len(val))
+	copy(newVal, val)
+	if end := offset + len(value); end > len(newVal) {
+		newVal = append(newVal, make([]byte, end-len(newVal))...)
+	}
+	copy(newVal[offset:], value)
+
+	if err := db.setValue(key, newVal); err != nil {
+		return 0, err
+	}
+	return len(newVal), nil
+}
+
+// GetDel 获取 key 的值并删除 key
+func (db *KvDB) GetDel(key []byte) ([]byte, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, String)
+	val, err := db.getValue(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.deleteKey(key, String); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// GetExOptions GetEx 的可选参数
+type GetExOptions struct {
+	ExpireAt int64 // 新的过期时间点（毫秒级 Unix 时间戳），0 表示不修改过期时间
+	Persist  bool  // 清除 key 的过期时间
+}
+
+// GetEx 获取 key 的值，同时按照选项修改其过期时间，对应 GETEX key [EX|PX|EXAT|PXAT|PERSIST]
+func (db *KvDB) GetEx(key []byte, opts GetExOptions) ([]byte, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+	if opts.ExpireAt < 0 || (opts.ExpireAt > 0 && opts.Persist) {
+		return nil, ErrInvalidGetExOptions
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, String)
+	val, err := db.getValue(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ExpireAt > 0 {
+		if err := db.setExpire(key, String, uint64(opts.ExpireAt)); err != nil {
+			return nil, err
+		}
+		db.expireIfNeeded(key, String) // 过期时间点已过去时直接删除
+	} else if opts.Persist {
+		if err := db.removeExpire(key, String); err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+// BitOperation BitOp 支持的位运算
+type BitOperation uint8
+
+const (
+	BitAnd BitOperation = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// BitRange BitCount 和 BitPos 的查找范围，负数表示从末尾开始计算的位置
+type BitRange struct {
+	Start  int
+	End    int
+	HasEnd bool // 是否指定了 End，未指定时视为 -1
+	Bit    bool // 为 true 时 Start 和 End 以位为单位，否则以字节为单位
+}
+
+// SetBit 设置 key 中字符串值在 offset 处的位，key 不存在时视为空字符串，长度不够时以零字节填充
+// 位的顺序与 Redis 相同，offset 为0表示第一个字节的最高位，返回该位原来的值
+func (db *KvDB) SetBit(key []byte, offset int, bit int) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, ErrInvalidOffset
+	}
+	if bit != 0 && bit != 1 {
+		return 0, ErrInvalidBit
+	}
+	byteIdx := offset / 8
+	if uint64(byteIdx) >= uint64(db.config.MaxValueSize) {
+		return 0, ErrValueTooLarge
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, String)
+	val, err := db.getValue(key)
+	if err != nil && err != ErrKeyNotExist {
+		return 0, err
+	}
+
+	size := len(val)
+	if byteIdx >= size {
+		size = byteIdx + 1
+	}
+	newVal := make([]byte, size)
+	copy(newVal, val)
+
+	mask := byte(0x80) >> uint(offset%8)
+	old := 0
+	if newVal[byteIdx]&mask != 0 {
+		old = 1
+	}
+	if bit == 1 {
+		newVal[byteIdx] |= mask
+	} else {
+		newVal[byteIdx] &^= mask
+	}
+
+	if err := db.setValue(key, newVal); err != nil {
+		return 0, err
+	}
+	return old, nil
+}
+
+// GetBit 返回 key 中字符串值在 offset 处的位，超出长度或 key 不存在时返回0
+func (db *KvDB) GetBit(key []byte, offset int) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, ErrInvalidOffset
 	}
 
-	db.strIndex.mu.RLock() // 加读锁对跳表进行操作
+	db.strIndex.mu.RLock()
 	defer db.strIndex.mu.RUnlock()
 
-	for node != nil && bytes.Compare(node.Key(), end) <= 0 { // 从start节点开始往后遍历，直接和end节点比较
-		if db.expireIfNeeded(node.Key()) { // 如果中间某个节点过期了，就跳过该节点
-			node = node.Next()
-			continue
+	val, err := db.getValue(key)
+	if err != nil && err != ErrKeyNotExist {
+		return 0, err
+	}
+
+	if byteIdx := offset / 8; byteIdx < len(val) && val[byteIdx]&(byte(0x80)>>uint(offset%8)) != 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// BitCount 返回 key 中字符串值在范围 r 内被设置为1的位的个数，r 为 nil 时统计整个字符串
+func (db *KvDB) BitCount(key []byte, r *BitRange) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	val, err := db.getValue(key)
+	if err != nil && err != ErrKeyNotExist {
+		return 0, err
+	}
+
+	startBit, endBit, ok := bitRange(len(val), r)
+	if !ok {
+		return 0, nil
+	}
+	return countBits(val, startBit, endBit), nil
+}
+
+// BitPos 返回 key 中字符串值在范围 r 内第一个值为 bit 的位的位置，r 为 nil 时查找整个字符串
+// 找不到时返回 -1；查找0且没有指定 End 时，如果范围内的位都是1，则返回字符串末尾之后的第一个位置
+func (db *KvDB) BitPos(key []byte, bit int, r *BitRange) (int, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0, err
+	}
+	if bit != 0 && bit != 1 {
+		return 0, ErrInvalidBit
+	}
+
+	db.strIndex.mu.RLock()
+	defer db.strIndex.mu.RUnlock()
+
+	val, err := db.getValue(key)
+	if err != nil && err != ErrKeyNotExist {
+		return 0, err
+	}
+	if len(val) == 0 { // 空字符串视为无限长的0
+		if bit == 0 {
+			return 0, nil
 		}
-		var value []byte
-		if db.config.IdxMode == KeyOnlyRamMode { // 仍然是要判断配置的是键值都在内存中还是另一种
-			value, err = db.Get(node.Key())
-			if err != nil {
-				return nil, err
+		return -1, nil
+	}
+
+	startBit, endBit, ok := bitRange(len(val), r)
+	if !ok {
+		return -1, nil
+	}
+	if pos := findBit(val, bit, startBit, endBit); pos >= 0 {
+		return pos, nil
+	}
+	if bit == 0 && (r == nil || !r.HasEnd) {
+		return (endBit/8 + 1) * 8, nil
+	}
+	return -1, nil
+}
+
+// BitOp 对一个或多个 key 中的字符串值进行位运算，并将结果保存到 destKey，不存在的 key 视为空字符串
+// 较短的字符串以零字节补齐，BitNot 只接受一个 key，结果为空时删除 destKey，返回结果的长度
+func (db *KvDB) BitOp(op BitOperation, destKey []byte, keys ...[]byte) (int, error) {
+	if op > BitNot || len(keys) == 0 || (op == BitNot && len(keys) != 1) {
+		return 0, ErrInvalidBitOp
+	}
+	if err := db.checkKeyValue(destKey, nil); err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := db.checkKeyValue(key, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	var values [][]byte
+	maxLen := 0
+	for _, key := range keys {
+		db.expireIfNeeded(key, String)
+		val, err := db.getValue(key)
+		if err != nil && err != ErrKeyNotExist {
+			return 0, err
+		}
+		values = append(values, val)
+		if len(val) > maxLen {
+			maxLen = len(val)
+		}
+	}
+
+	res := make([]byte, maxLen)
+	copy(res, values[0])
+	if op == BitNot {
+		for i := range res {
+			res[i] = ^res[i]
+		}
+	}
+	for _, val := range values[1:] {
+		for i := range res {
+			var b byte
+			if i < len(val) {
+				b = val[i]
+			}
+			switch op {
+			case BitAnd:
+				res[i] &= b
+			case BitOr:
+				res[i] |= b
+			case BitXor:
+				res[i] ^= b
 			}
-		} else {
-			value = node.Value().(*index.Indexer).Meta.Value
 		}
+	}
+
+	db.expireIfNeeded(destKey, String)
+	if maxLen == 0 {
+		_, err := db.deleteKey(destKey, String)
+		return 0, err
+	}
+	if err := db.setValues([]KV{{Key: destKey, Value: res}}); err != nil {
+		return 0, err
+	}
+	return maxLen, nil
+}
+
+// 将范围 r 转换为位的范围 [startBit, endBit]，范围为空时 ok 为 false
+func bitRange(size int, r *BitRange) (startBit, endBit int, ok bool) {
+	if size == 0 {
+		return
+	}
+	if r == nil {
+		return 0, size*8 - 1, true
+	}
+
+	length := size
+	if r.Bit {
+		length = size * 8
+	}
+	start, end := r.Start, -1
+	if r.HasEnd {
+		end = r.End
+	}
+
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end {
+		return
+	}
 
-		val = append(val, value) // 将查出来的value放入结果集中
-		node = node.Next()
+	if r.Bit {
+		return start, end, true
+	}
+	return start * 8, end*8 + 7, true
+}
+
+// 统计 [startBit, endBit] 范围内值为1的位的个数
+func countBits(val []byte, startBit, endBit int) (n int) {
+	first, last := startBit/8, endBit/8
+	headMask := byte(0xff) >> uint(startBit%8)
+	tailMask := byte(0xff) << uint(7-endBit%8)
+	if first == last {
+		return bits.OnesCount8(val[first] & headMask & tailMask)
 	}
 
+	n = bits.OnesCount8(val[first]&headMask) + bits.OnesCount8(val[last]&tailMask)
+
+	// 中间的完整字节每次按64位统计
+	mid := val[first+1 : last]
+	for len(mid) >= 8 {
+		n += bits.OnesCount64(binary.LittleEndian.Uint64(mid))
+		mid = mid[8:]
+	}
+	for _, b := range mid {
+		n += bits.OnesCount8(b)
+	}
 	return
 }
 
-// Expire 设置key的过期时间
-func (db *KvDB) Expire(key []byte, seconds uint32) (err error) {
-	if exist := db.StrExists(key); !exist {
-		return ErrKeyNotExist
+// 查找 [startBit, endBit] 范围内第一个值为 bit 的位，找不到时返回 -1
+func findBit(val []byte, bit int, startBit, endBit int) int {
+	first, last := startBit/8, endBit/8
+	for i := first; i <= last; i++ {
+		b := val[i]
+		if bit == 0 {
+			b = ^b
+		}
+		if i == first {
+			b &= byte(0xff) >> uint(startBit%8)
+		}
+		if i == last {
+			b &= byte(0xff) << uint(7-endBit%8)
+		}
+		if b != 0 {
+			return i*8 + bits.LeadingZeros8(b)
+		}
+	}
+	return -1
+}
+
+// Incr 将 key 中储存的整数值加一，key 不存在时先初始化为0，返回加一之后的值
+func (db *KvDB) Incr(key []byte) (int64, error) {
+	return db.IncrBy(key, 1)
+}
+
+// Decr 将 key 中储存的整数值减一，key 不存在时先初始化为0，返回减一之后的值
+func (db *KvDB) Decr(key []byte) (int64, error) {
+	return db.IncrBy(key, -1)
+}
+
+// DecrBy 将 key 中储存的整数值减去 decrement
+func (db *KvDB) DecrBy(key []byte, decrement int64) (int64, error) {
+	if decrement == math.MinInt64 {
+		return 0, ErrIncrOverflow
 	}
-	if seconds <= 0 {
-		return ErrInvalidTTL
+	return db.IncrBy(key, -decrement)
+}
+
+// IncrBy 将 key 中储存的整数值加上 increment，key 不存在时先初始化为0，返回相加之后的值
+// 值不是整数时返回 ErrValueNotInteger，结果超出 int64 范围时返回 ErrIncrOverflow，原有的过期时间保持不变
+func (db *KvDB) IncrBy(key []byte, increment int64) (res int64, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
 	}
 
 	db.strIndex.mu.Lock()
 	defer db.strIndex.mu.Unlock()
 
-	deadline := uint32(time.Now().Unix()) + seconds
-	db.expires[string(key)] = deadline
+	db.expireIfNeeded(key, String)
+	val, err := db.getValue(key)
+	if err != nil && err != ErrKeyNotExist {
+		return
+	}
+
+	if res, err = incrInt(val, increment); err != nil {
+		return
+	}
+	err = db.setValue(key, []byte(strconv.FormatInt(res, 10)))
 	return
 }
 
-// Persist 清除key的过期时间
-func (db *KvDB) Persist(key []byte) {
+// IncrByFloat 将 key 中储存的浮点数值加上 increment，key 不存在时先初始化为0，返回相加之后的值
+// 值不是浮点数时返回 ErrValueNotFloat，结果为 NaN 或无穷大时返回 ErrIncrOverflow，原有的过期时间保持不变
+func (db *KvDB) IncrByFloat(key []byte, increment float64) (res float64, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
 
 	db.strIndex.mu.Lock()
 	defer db.strIndex.mu.Unlock()
 
-	delete(db.expires, string(key))
+	db.expireIfNeeded(key, String)
+	val, err := db.getValue(key)
+	if err != nil && err != ErrKeyNotExist {
+		return
+	}
+
+	if res, err = incrFloat(val, increment); err != nil {
+		return
+	}
+	err = db.setValue(key, []byte(formatFloat(res)))
+	return
 }
 
-// TTL 获取key的过期时间
-func (db *KvDB) TTL(key []byte) (ttl uint32) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+// 获取未过期的key的值，key不存在时返回 ErrKeyNotExist，调用方需持有 strIndex 的锁
+func (db *KvDB) getValue(key []byte) ([]byte, error) {
+	if db.isExpired(key, String) {
+		return nil, ErrKeyNotExist
+	}
 
-	if db.expireIfNeeded(key) {
-		return
+	node := db.strIndex.idxList.Get(key)
+	if node == nil {
+		return nil, ErrKeyNotExist
 	}
-	deadline, exist := db.expires[string(key)]
-	if !exist {
-		return
+	idx := node.Value().(*index.Indexer)
+	if idx == nil {
+		return nil, ErrNilIndexer
 	}
 
-	now := uint32(time.Now().Unix())
-	if deadline > now {
-		ttl = deadline - now
+	if db.config.IdxMode == KeyValueRamMode {
+		return idx.Meta.Value, nil
 	}
-	return
+	return db.readValue(String, idx)
 }
 
-// 检查key是否过期并删除相应的值
-func (db *KvDB) expireIfNeeded(key []byte) (expired bool) {
-	deadline := db.expires[string(key)]
-	if deadline <= 0 {
-		return
+// 将 val 解析为整数后加上 increment，val 为 nil 时视为0
+func incrInt(val []byte, increment int64) (int64, error) {
+	var n int64
+	if val != nil {
+		var err error
+		if n, err = strconv.ParseInt(string(val), 10, 64); err != nil {
+			return 0, ErrValueNotInteger
+		}
 	}
 
-	if time.Now().Unix() > int64(deadline) {
-		expired = true
-		//删除过期字典对应的key
-		delete(db.expires, string(key))
+	if (increment > 0 && n > math.MaxInt64-increment) || (increment < 0 && n < math.MinInt64-increment) {
+		return 0, ErrIncrOverflow
+	}
+	return n + increment, nil
+}
 
-		//删除索引及数据
-		if ele := db.strIndex.idxList.Remove(key); ele != nil {
-			e := storage.NewEntryNoExtra(key, nil, String, StringRem)
-			if err := db.store(e); err != nil {
-				log.Printf("remove expired key err [%+v] [%+v]\n", key, err)
-			}
+// 将 val 解析为浮点数后加上 increment，val 为 nil 时视为0
+func incrFloat(val []byte, increment float64) (float64, error) {
+	var f float64
+	if val != nil {
+		var err error
+		if f, err = strconv.ParseFloat(string(val), 64); err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, ErrValueNotFloat
 		}
 	}
-	return
+
+	f += increment
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrIncrOverflow
+	}
+	return f, nil
+}
+
+// 将浮点数格式化为不带指数的最短表示
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
 }
 
 func (db *KvDB) doSet(key, value []byte) (err error) {
@@ -386,16 +999,21 @@ func (db *KvDB) doSet(key, value []byte) (err error) {
 		return err
 	}
 
+	db.strIndex.mu.Lock()
+	defer db.strIndex.mu.Unlock()
+
+	return db.setValue(key, value)
+}
+
+// 写入字符串数据并更新索引，调用方需持有 strIndex 的写锁
+func (db *KvDB) setValue(key, value []byte) (err error) {
 	// 如果新增的 value 和设置的 value 一样，则不做任何操作
-	if db.config.IdxMode == KeyValueRamMode {
-		if existVal, _ := db.Get(key); existVal != nil && bytes.Compare(existVal, value) == 0 {
+	if db.config.IdxMode == KeyValueRamMode && !db.isExpired(key, String) {
+		if node := db.strIndex.idxList.Get(key); node != nil && bytes.Compare(node.Value().(*index.Indexer).Meta.Value, value) == 0 {
 			return
 		}
 	}
 
-	db.strIndex.mu.Lock()
-	defer db.strIndex.mu.Unlock()
-
 	e := storage.NewEntryNoExtra(key, value, String, StringSet)
 	if err := db.store(e); err != nil {
 		return err
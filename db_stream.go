@@ -0,0 +1,505 @@
+package KV_Storage
+
+import (
+	"KV_Storage/ds/stream"
+	"KV_Storage/storage"
+	"KV_Storage/utils"
+	"strconv"
+	"sync"
+)
+
+//流相关操作接口
+
+// StreamIdx the stream idx
+type StreamIdx struct {
+	mu      sync.RWMutex
+	indexes *stream.Stream
+}
+
+func newStreamIdx() *StreamIdx {
+	return &StreamIdx{indexes: stream.New()}
+}
+
+// StreamEntries 一个流中读取到的消息
+type StreamEntries struct {
+	Key     []byte
+	Entries []stream.Entry
+}
+
+// XAdd 将消息加入流 key 的末尾，流不存在时创建一个新的流，fields 中域和值交替排列
+// id 为 * 时根据当前时间自动生成，为 ms-* 时自动生成序号，否则必须大于流中已有的ID，返回加入的消息的ID
+func (db *KvDB) XAdd(key []byte, id string, fields ...[]byte) (res string, err error) {
+	if err = db.checkKeyValue(key, fields...); err != nil {
+		return
+	}
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return "", ErrInvalidStreamFields
+	}
+
+	db.streamIndex.mu.Lock()
+	defer db.streamIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, Stream)
+
+	streamID, err := db.streamIndex.indexes.NextID(string(key), id, nowMillis())
+	if err != nil {
+		return
+	}
+
+	// 一条消息只能作为一条日志记录，编码后超出大小限制时拒绝写入
+	e := storage.NewEntry(key, utils.EncodeValues(fields), []byte(streamID.String()), Stream, StreamXAdd)
+	if !db.fitsInEntry(e) {
+		return "", ErrValueTooLarge
+	}
+	if err = db.store(e); err != nil {
+		return
+	}
+
+	db.streamIndex.indexes.XAdd(string(key), streamID, fields)
+	return streamID.String(), nil
+}
+
+// XLen 返回流 key 中消息的个数
+func (db *KvDB) XLen(key []byte) int {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return 0
+	}
+
+	db.streamIndex.mu.RLock()
+	defer db.streamIndex.mu.RUnlock()
+
+	if db.isExpired(key, Stream) {
+		return 0
+	}
+
+	return db.streamIndex.indexes.XLen(string(key))
+}
+
+// XRange 返回流 key 中ID介于 start 和 end 之间的消息，按ID递增排列，count 大于0时最多返回 count 条
+// - 和 + 分别表示最小和最大的ID，以 ( 开头表示不包含该ID，省略序号时 start 的序号为0，end 的序号为最大值
+func (db *KvDB) XRange(key []byte, start, end string, count int) ([]stream.Entry, error) {
+	return db.xRange(key, start, end, count, false)
+}
+
+// XRevRange 与 XRange 相同，但按ID递减的顺序返回，注意参数中 end 在 start 之前
+func (db *KvDB) XRevRange(key []byte, end, start string, count int) ([]stream.Entry, error) {
+	return db.xRange(key, start, end, count, true)
+}
+
+func (db *KvDB) xRange(key []byte, start, end string, count int, reverse bool) ([]stream.Entry, error) {
+	if err := db.checkKeyValue(key, nil); err != nil {
+		return nil, err
+	}
+	startID, ok, err := parseStreamRangeID(start, false)
+	if err != nil || !ok {
+		return nil, err
+	}
+	endID, ok, err := parseStreamRangeID(end, true)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	db.streamIndex.mu.RLock()
+	defer db.streamIndex.mu.RUnlock()
+
+	if db.isExpired(key, Stream) {
+		return nil, nil
+	}
+
+	return db.streamIndex.indexes.XRange(string(key), startID, endID, count, reverse), nil
+}
+
+// XRead 从一个或多个流中读取ID大于对应 ids 的消息，每个流最多返回 count 条（count 大于0时）
+// id 为 $ 时表示流中当前最大的ID，即只读取之后加入的消息，没有消息的流不会出现在结果中
+func (db *KvDB) XRead(keys [][]byte, ids []string, count int) (res []StreamEntries, err error) {
+	if len(keys) == 0 || len(keys) != len(ids) {
+		return nil, ErrInvalidStreamFields
+	}
+	for _, key := range keys {
+		if err = db.checkKeyValue(key, nil); err != nil {
+			return
+		}
+	}
+
+	db.streamIndex.mu.RLock()
+	defer db.streamIndex.mu.RUnlock()
+
+	for i, key := range keys {
+		if db.isExpired(key, Stream) {
+			continue
+		}
+
+		var after stream.ID
+		if ids[i] == "$" {
+			after = db.streamIndex.indexes.LastID(string(key))
+		} else if after, err = stream.ParseID(ids[i], 0); err != nil {
+			return nil, err
+		}
+
+		start, ok := after.Next()
+		if !ok {
+			continue
+		}
+		entries := db.streamIndex.indexes.XRange(string(key), start, stream.MaxID, count, false)
+		if len(entries) > 0 {
+			res = append(res, StreamEntries{Key: key, Entries: entries})
+		}
+	}
+	return
+}
+
+// XGroupCreate 为流 key 创建消费者组 group，组中只会投递ID大于 id 的消息，id 为 $ 时表示流中当前最大的ID
+// 流不存在时，mkStream 为 true 则创建一个空的流，否则返回 ErrKeyNotExist；组已存在时返回 stream.ErrGroupExists
+func (db *KvDB) XGroupCreate(key, group []byte, id string, mkStream bool) error {
+	if err := db.checkKeyValue(key, group); err != nil {
+		return err
+	}
+
+	db.streamIndex.mu.Lock()
+	defer db.streamIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, Stream)
+
+	k := string(key)
+	if !mkStream && !db.streamIndex.indexes.Exists(k) {
+		return ErrKeyNotExist
+	}
+	if db.streamIndex.indexes.GroupExists(k, string(group)) {
+		return stream.ErrGroupExists
+	}
+
+	var groupID stream.ID
+	if id == "$" {
+		groupID = db.streamIndex.indexes.LastID(k)
+	} else {
+		var err error
+		if groupID, err = stream.ParseID(id, 0); err != nil {
+			return err
+		}
+	}
+
+	e := storage.NewEntry(key, group, []byte(groupID.String()), Stream, StreamXGroupCreate)
+	if err := db.store(e); err != nil {
+		return err
+	}
+	return db.streamIndex.indexes.XGroupCreate(k, string(group), groupID)
+}
+
+// XReadGroup 以消费者组 group 中消费者 consumer 的身份从一个或多个流中读取消息
+// id 为 > 时读取从未投递给该组的新消息，并将其记录为该消费者未确认的消息（noAck 为 true 时不记录）
+// 否则读取该消费者未确认的消息中ID大于 id 的部分，并增加它们的投递次数
+// 任一流中不存在该组时返回 stream.ErrNoGroup
+func (db *KvDB) XReadGroup(group, consumer []byte, keys [][]byte, ids []string, count int, noAck bool) (res []StreamEntries, err error) {
+	if len(keys) == 0 || len(keys) != len(ids) || len(consumer) == 0 {
+		return nil, ErrInvalidStreamFields
+	}
+	for _, key := range keys {
+		if err = db.checkKeyValue(key, group); err != nil {
+			return
+		}
+	}
+
+	db.streamIndex.mu.Lock()
+	defer db.streamIndex.mu.Unlock()
+
+	g := string(group)
+	for _, key := range keys {
+		db.expireIfNeeded(key, Stream)
+		if !db.streamIndex.indexes.GroupExists(string(key), g) {
+			return nil, stream.ErrNoGroup
+		}
+	}
+
+	now := nowMillis()
+	for i, key := range keys {
+		var entries []stream.Entry
+		if ids[i] == ">" {
+			entries, err = db.xReadGroupNew(key, g, string(consumer), count, noAck, now)
+		} else {
+			entries, err = db.xReadGroupPending(key, g, string(consumer), ids[i], count, now)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 || ids[i] != ">" {
+			res = append(res, StreamEntries{Key: key, Entries: entries})
+		}
+	}
+	return
+}
+
+// 读取从未投递给消费者组的新消息，调用方需持有 streamIndex 的写锁
+func (db *KvDB) xReadGroupNew(key []byte, group, consumer string, count int, noAck bool, now uint64) ([]stream.Entry, error) {
+	k := string(key)
+	start, ok := db.streamIndex.indexes.GroupLastID(k, group).Next()
+	if !ok {
+		return nil, nil
+	}
+	entries := db.streamIndex.indexes.XRange(k, start, stream.MaxID, count, false)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	var pending []stream.PendingEntry
+	if !noAck {
+		for _, e := range entries {
+			pending = append(pending, stream.PendingEntry{ID: e.ID, Consumer: consumer, DeliveryTime: now, DeliveryCount: 1})
+		}
+	}
+	lastID := entries[len(entries)-1].ID
+	return entries, db.streamClaim(key, group, lastID, pending)
+}
+
+// 重新读取消费者未确认的消息，调用方需持有 streamIndex 的写锁
+func (db *KvDB) xReadGroupPending(key []byte, group, consumer, id string, count int, now uint64) ([]stream.Entry, error) {
+	after, err := stream.ParseID(id, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, ok := after.Next()
+	if !ok {
+		return nil, nil
+	}
+
+	k := string(key)
+	pending := db.streamIndex.indexes.Pending(k, group, start, stream.MaxID, count, consumer)
+	var entries []stream.Entry
+	for i := range pending {
+		pending[i].DeliveryTime = now
+		pending[i].DeliveryCount++
+		if e, ok := db.streamIndex.indexes.Get(k, pending[i].ID); ok {
+			entries = append(entries, e)
+		} else {
+			entries = append(entries, stream.Entry{ID: pending[i].ID})
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	return entries, db.streamClaim(key, group, stream.ID{}, pending)
+}
+
+// XAck 确认消费者组 group 中的消息，返回被确认的消息个数，ID 格式不正确时返回 stream.ErrInvalidID
+func (db *KvDB) XAck(key, group []byte, ids ...string) (res int, err error) {
+	if err = db.checkKeyValue(key, group); err != nil {
+		return
+	}
+
+	var streamIDs []stream.ID
+	for _, id := range ids {
+		streamID, err := stream.ParseID(id, 0)
+		if err != nil {
+			return 0, err
+		}
+		streamIDs = append(streamIDs, streamID)
+	}
+
+	db.streamIndex.mu.Lock()
+	defer db.streamIndex.mu.Unlock()
+
+	db.expireIfNeeded(key, Stream)
+
+	k, g := string(key), string(group)
+	var acked []stream.ID
+	for _, id := range streamIDs {
+		if len(db.streamIndex.indexes.Pending(k, g, id, id, 1, "")) > 0 {
+			acked = append(acked, id)
+		}
+	}
+	if len(acked) == 0 {
+		return
+	}
+
+	// 确认的消息过多时分为多条日志，每条单独确认其中的消息
+	ends, err := db.entryBatches(key, utils.EncodedSize([][]byte{group}), len(acked), func(i int) int {
+		return utils.EncodedSize([][]byte{[]byte(acked[i].String())})
+	})
+	if err != nil {
+		return
+	}
+	start := 0
+	for _, end := range ends {
+		e := storage.NewEntryNoExtra(key, encodeStreamAck(g, acked[start:end]), Stream, StreamXAck)
+		if err = db.store(e); err != nil {
+			return
+		}
+		res += db.streamIndex.indexes.XAck(k, g, acked[start:end]...)
+		start = end
+	}
+	return
+}
+
+// XPending 返回消费者组 group 中ID介于 start 和 end 之间的未确认消息，start 和 end 的格式与 XRange 相同
+// consumer 不为空时只返回该消费者的消息，count 大于0时最多返回 count 条，组不存在时返回 stream.ErrNoGroup
+func (db *KvDB) XPending(key, group []byte, start, end string, count int, consumer []byte) ([]stream.PendingEntry, error) {
+	if err := db.checkKeyValue(key, group); err != nil {
+		return nil, err
+	}
+	startID, ok, err := parseStreamRangeID(start, false)
+	if err != nil || !ok {
+		return nil, err
+	}
+	endID, ok, err := parseStreamRangeID(end, true)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	db.streamIndex.mu.RLock()
+	defer db.streamIndex.mu.RUnlock()
+
+	if db.isExpired(key, Stream) || !db.streamIndex.indexes.GroupExists(string(key), string(group)) {
+		return nil, stream.ErrNoGroup
+	}
+	return db.streamIndex.indexes.Pending(string(key), string(group), startID, endID, count, string(consumer)), nil
+}
+
+// 记录消费者组的投递状态，调用方需持有 streamIndex 的写锁
+// 未确认消息过多而超出一条日志的大小限制时分为多条记录，lastID 只记录在最后一条中，
+// 因此中途崩溃时已投递的消息会再次作为新消息投递，而不会丢失
+func (db *KvDB) streamClaim(key []byte, group string, lastID stream.ID, pending []stream.PendingEntry) error {
+	base := utils.EncodedSize([][]byte{[]byte(group), []byte(lastID.String())})
+	ends, err := db.entryBatches(key, base, len(pending), func(i int) int {
+		return utils.EncodedSize(pendingValues(pending[i]))
+	})
+	if err != nil {
+		return err
+	}
+
+	start := 0
+	for i, end := range ends {
+		var id stream.ID
+		if i == len(ends)-1 {
+			id = lastID
+		}
+		batch := pending[start:end]
+		e := storage.NewEntryNoExtra(key, encodeStreamClaim(group, id, batch), Stream, StreamXClaim)
+		if err := db.store(e); err != nil {
+			return err
+		}
+		if err := db.streamIndex.indexes.SetPending(string(key), group, id, batch); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// 解析 XRange 的边界，返回的 ok 为 false 时表示范围为空（如 (+ 或 (-）
+func parseStreamRangeID(s string, isEnd bool) (id stream.ID, ok bool, err error) {
+	switch s {
+	case "-":
+		return stream.ID{}, true, nil
+	case "+":
+		return stream.MaxID, true, nil
+	}
+
+	exclusive := len(s) > 0 && s[0] == '('
+	if exclusive {
+		s = s[1:]
+	}
+
+	var defaultSeq uint64
+	if isEnd {
+		defaultSeq = stream.MaxID.Seq
+	}
+	if id, err = stream.ParseID(s, defaultSeq); err != nil {
+		return
+	}
+	if !exclusive {
+		return id, true, nil
+	}
+
+	if isEnd {
+		// 不包含 end，即取比 end 小的最大ID
+		if id == (stream.ID{}) {
+			return id, false, nil
+		}
+		if id.Seq > 0 {
+			id.Seq--
+		} else {
+			id.Ms, id.Seq = id.Ms-1, stream.MaxID.Seq
+		}
+		return id, true, nil
+	}
+	next, ok := id.Next()
+	return next, ok, nil
+}
+
+// 投递状态的日志格式：组名、已投递的最大ID，以及每条未确认消息的ID、消费者、投递时间和投递次数
+func encodeStreamClaim(group string, lastID stream.ID, pending []stream.PendingEntry) []byte {
+	values := [][]byte{[]byte(group), []byte(lastID.String())}
+	for _, pe := range pending {
+		values = append(values, pendingValues(pe)...)
+	}
+	return utils.EncodeValues(values)
+}
+
+func pendingValues(pe stream.PendingEntry) [][]byte {
+	return [][]byte{
+		[]byte(pe.ID.String()),
+		[]byte(pe.Consumer),
+		[]byte(strconv.FormatUint(pe.DeliveryTime, 10)),
+		[]byte(strconv.FormatUint(pe.DeliveryCount, 10)),
+	}
+}
+
+func decodeStreamClaim(buf []byte) (group string, lastID stream.ID, pending []stream.PendingEntry, err error) {
+	values, err := utils.DecodeValues(buf)
+	if err != nil {
+		return
+	}
+	if len(values) < 2 || (len(values)-2)%4 != 0 {
+		err = stream.ErrInvalidValue
+		return
+	}
+
+	group = string(values[0])
+	if lastID, err = stream.ParseID(string(values[1]), 0); err != nil {
+		return
+	}
+	for i := 2; i < len(values); i += 4 {
+		var pe stream.PendingEntry
+		if pe.ID, err = stream.ParseID(string(values[i]), 0); err != nil {
+			return
+		}
+		pe.Consumer = string(values[i+1])
+		if pe.DeliveryTime, err = strconv.ParseUint(string(values[i+2]), 10, 64); err != nil {
+			return
+		}
+		if pe.DeliveryCount, err = strconv.ParseUint(string(values[i+3]), 10, 64); err != nil {
+			return
+		}
+		pending = append(pending, pe)
+	}
+	return
+}
+
+// 确认消息的日志格式：组名以及被确认的消息ID
+func encodeStreamAck(group string, ids []stream.ID) []byte {
+	values := [][]byte{[]byte(group)}
+	for _, id := range ids {
+		values = append(values, []byte(id.String()))
+	}
+	return utils.EncodeValues(values)
+}
+
+func decodeStreamAck(buf []byte) (group string, ids []stream.ID, err error) {
+	values, err := utils.DecodeValues(buf)
+	if err != nil {
+		return
+	}
+	if len(values) < 1 {
+		err = stream.ErrInvalidValue
+		return
+	}
+
+	group = string(values[0])
+	for _, v := range values[1:] {
+		id, err := stream.ParseID(string(v), 0)
+		if err != nil {
+			return "", nil, err
+		}
+		ids = append(ids, id)
+	}
+	return
+}
@@ -4,6 +4,7 @@ import (
 	"KV_Storage/ds/zset"
 	"KV_Storage/storage"
 	"KV_Storage/utils"
+	"math"
 	"sync"
 )
 
@@ -34,6 +35,8 @@ func (db *KvDB) ZAdd(key []byte, score float64, member []byte) error {
 	db.zsetIndex.mu.Lock()
 	defer db.zsetIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, ZSet)
+
 	extra := []byte(utils.Float64ToStr(score))
 	e := storage.NewEntry(key, member, extra, ZSet, ZSetZAdd)
 	if err := db.store(e); err != nil {
@@ -50,6 +53,10 @@ func (db *KvDB) ZScore(key, member []byte) float64 {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return math.MinInt64
+	}
+
 	return db.zsetIndex.indexes.ZScore(string(key), string(member))
 }
 
@@ -59,6 +66,10 @@ func (db *KvDB) ZCard(key []byte) int {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return 0
+	}
+
 	return db.zsetIndex.indexes.ZCard(string(key))
 }
 
@@ -73,6 +84,10 @@ func (db *KvDB) ZRank(key, member []byte) int64 {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return -1
+	}
+
 	return db.zsetIndex.indexes.ZRank(string(key), string(member))
 }
 
@@ -87,6 +102,10 @@ func (db *KvDB) ZRevRank(key, member []byte) int64 {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return -1
+	}
+
 	return db.zsetIndex.indexes.ZRevRank(string(key), string(member))
 }
 
@@ -101,6 +120,8 @@ func (db *KvDB) ZIncrBy(key []byte, increment float64, member []byte) (float64,
 	db.zsetIndex.mu.Lock()
 	defer db.zsetIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, ZSet)
+
 	increment = db.zsetIndex.indexes.ZIncrBy(string(key), increment, string(member))
 
 	extra := utils.Float64ToStr(increment)
@@ -123,9 +144,41 @@ func (db *KvDB) ZRange(key []byte, start, stop int) []interface{} {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
 	return db.zsetIndex.indexes.ZRange(string(key), start, stop)
 }
 
+// ZScan 基于游标迭代有序集合 key 中的成员，返回的 memberScores 中成员和分数交替排列
+// cursor、pattern 和 count 的含义与 Scan 相同，pattern 用于过滤成员
+func (db *KvDB) ZScan(key []byte, cursor string, pattern []byte, count int) (next string, memberScores []interface{}, err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	sc, err := newScanCursor(cursor, count)
+	if err != nil {
+		return
+	}
+
+	db.zsetIndex.mu.RLock()
+	defer db.zsetIndex.mu.RUnlock()
+
+	if db.isExpired(key, ZSet) {
+		return "0", nil, nil
+	}
+
+	db.zsetIndex.indexes.ZScan(string(key), sc.after, sc.bounded, sc.add)
+	next, members := sc.page()
+	for _, m := range members {
+		if len(pattern) == 0 || utils.GlobMatch(pattern, []byte(m)) {
+			memberScores = append(memberScores, m, db.zsetIndex.indexes.ZScore(string(key), m))
+		}
+	}
+	return
+}
+
 // ZRevRange 返回有序集 key 中，指定区间内的成员，其中成员的位置按 score 值递减(从大到小)来排列
 // 具有相同 score 值的成员按字典序的逆序(reverse lexicographical order)排列
 func (db *KvDB) ZRevRange(key []byte, start, stop int) []interface{} {
@@ -137,6 +190,10 @@ func (db *KvDB) ZRevRange(key []byte, start, stop int) []interface{} {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
 	return db.zsetIndex.indexes.ZRevRange(string(key), start, stop)
 }
 
@@ -150,6 +207,9 @@ func (db *KvDB) ZRem(key, member []byte) (ok bool, err error) {
 	db.zsetIndex.mu.Lock()
 	defer db.zsetIndex.mu.Unlock()
 
+	db.expireIfNeeded(key, ZSet)
+	defer db.clearExpireIfEmpty(key, ZSet)
+
 	if ok = db.zsetIndex.indexes.ZRem(string(key), string(member)); ok {
 		e := storage.NewEntryNoExtra(key, member, ZSet, ZSetZRem)
 		if err = db.store(e); err != nil {
@@ -166,6 +226,10 @@ func (db *KvDB) ZGetByRank(key []byte, rank int) []interface{} {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
 	return db.zsetIndex.indexes.ZGetByRank(string(key), rank)
 }
 
@@ -175,6 +239,10 @@ func (db *KvDB) ZRevGetByRank(key []byte, rank int) []interface{} {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
 	return db.zsetIndex.indexes.ZRevGetByRank(string(key), rank)
 }
 
@@ -189,6 +257,10 @@ func (db *KvDB) ZScoreRange(key []byte, min, max float64) []interface{} {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
 	return db.zsetIndex.indexes.ZScoreRange(string(key), min, max)
 }
 
@@ -202,5 +274,9 @@ func (db *KvDB) ZRevScoreRange(key []byte, max, min float64) []interface{} {
 	db.zsetIndex.mu.RLock()
 	defer db.zsetIndex.mu.RUnlock()
 
+	if db.isExpired(key, ZSet) {
+		return nil
+	}
+
 	return db.zsetIndex.indexes.ZRevScoreRange(string(key), max, min)
 }
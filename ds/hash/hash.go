@@ -1,9 +1,13 @@
 package hash
 
+import "KV_Storage/utils"
+
 type (
 	// Hash 哈希表结构定义
 	Hash struct {
 		record Record
+		keys   *utils.OrderedKeys            // 所有非空哈希表的key，用于基于游标的迭代
+		fields map[string]*utils.OrderedKeys // 每个哈希表中的域，用于基于游标的迭代
 	}
 
 	// Record hash record to save
@@ -12,7 +16,7 @@ type (
 
 // New new a hash ds
 func New() *Hash {
-	return &Hash{make(Record)}
+	return &Hash{record: make(Record), keys: utils.NewOrderedKeys(), fields: make(map[string]*utils.OrderedKeys)}
 
 }
 
@@ -20,23 +24,20 @@ func New() *Hash {
 // 如果给定的哈希表并不存在， 那么一个新的哈希表将被创建并执行 HSet 操作
 // 如果域 field 已经存在于哈希表中， 那么它的旧值将被新值 value 覆盖
 func (h *Hash) HSet(key string, field string, value []byte) int {
-	if !h.exist(key) {
-		h.record[key] = make(map[string][]byte)
+	m := h.getOrCreate(key)
+	if _, exist := m[field]; !exist {
+		h.fields[key].Add(field)
 	}
 
-	h.record[key][field] = value
-	return len(h.record[key])
+	m[field] = value
+	return len(m)
 }
 
 // HSetNx 当且仅当域 field 尚未存在于哈希表的情况下， 将它的值设置为 value
 // 如果给定域已经存在于哈希表当中， 那么命令将放弃执行设置操作
 func (h *Hash) HSetNx(key string, field string, value []byte) bool {
-	if !h.exist(key) {
-		h.record[key] = make(map[string][]byte)
-	}
-
 	if _, exist := h.record[key][field]; !exist {
-		h.record[key][field] = value
+		h.HSet(key, field, value)
 		return true
 	}
 
@@ -74,12 +75,23 @@ func (h *Hash) HDel(key string, field string) bool {
 
 	if _, exist := h.record[key][field]; exist {
 		delete(h.record[key], field)
+		h.fields[key].Remove(field)
+		if len(h.record[key]) == 0 { // 删空的哈希表不再保留
+			h.HClear(key)
+		}
 		return true
 	}
 
 	return false
 }
 
+// HClear 删除整个哈希表
+func (h *Hash) HClear(key string) {
+	delete(h.record, key)
+	delete(h.fields, key)
+	h.keys.Remove(key)
+}
+
 // HExists 检查给定域 field 是否存在于key对应的哈希表中
 func (h *Hash) HExists(key, field string) bool {
 	if !h.exist(key) {
@@ -126,6 +138,58 @@ func (h *Hash) HValues(key string) (val [][]byte) {
 	return
 }
 
+// KeyCount 返回所有非空哈希表的key的个数
+func (h *Hash) KeyCount() int {
+	return h.keys.Len()
+}
+
+// Keys 返回所有非空哈希表的key
+func (h *Hash) Keys() (keys []string) {
+	for k, v := range h.record {
+		if len(v) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// Rename 将哈希表 key 转移到 newKey 下，newKey 原有的数据会被覆盖，key 不存在时不做任何操作
+func (h *Hash) Rename(key, newKey string) {
+	if v, ok := h.record[key]; ok {
+		fields := h.fields[key]
+		h.HClear(key)
+		h.HClear(newKey)
+		h.record[newKey] = v
+		h.fields[newKey] = fields
+		h.keys.Add(newKey)
+	}
+}
+
+// ScanKeys 按字典序依次将非空哈希表的key交给 fn，直到 fn 返回 false，用于基于游标的迭代
+// bounded 为 false 时从第一个key开始，否则从第一个大于 after 的key开始
+func (h *Hash) ScanKeys(after string, bounded bool, fn func(key string) bool) {
+	h.keys.Ascend(after, bounded, fn)
+}
+
+// HScan 按字典序依次将哈希表 key 中的域交给 fn，直到 fn 返回 false，after 和 bounded 的含义与 ScanKeys 相同
+func (h *Hash) HScan(key, after string, bounded bool, fn func(field string) bool) {
+	if fields, ok := h.fields[key]; ok {
+		fields.Ascend(after, bounded, fn)
+	}
+}
+
+// 返回哈希表 key，不存在时新建一个空的哈希表
+func (h *Hash) getOrCreate(key string) map[string][]byte {
+	m, ok := h.record[key]
+	if !ok {
+		m = make(map[string][]byte)
+		h.record[key] = m
+		h.fields[key] = utils.NewOrderedKeys()
+		h.keys.Add(key)
+	}
+	return m
+}
+
 // 检查哈希表结构中是否存在key对应的value
 func (h *Hash) exist(key string) bool {
 	_, exist := h.record[key]
@@ -0,0 +1,383 @@
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// 与 Redis 相同的 HyperLogLog 实现，序列化的格式也相同：
+// 16字节的头部：4字节的魔数 HYLL，1字节的编码方式，3字节保留，8字节缓存的基数（小端序，最高位为1表示缓存失效）
+// dense 编码：16384 个6位的寄存器，共 12288 字节
+// sparse 编码：由以下三种操作码组成的寄存器游程编码
+//   ZERO  00xxxxxx          连续 xxxxxx+1 (1-64) 个值为0的寄存器
+//   XZERO 01xxxxxx yyyyyyyy 连续 xxxxxxyyyyyyyy+1 (1-16384) 个值为0的寄存器
+//   VAL   1vvvvvxx          连续 xx+1 (1-4) 个值为 vvvvv+1 (1-32) 的寄存器
+
+var ErrInvalidHLL = errors.New("hll: value is not a valid HyperLogLog")
+
+const (
+	hllP         = 14                 // 寄存器索引的位数
+	hllQ         = 64 - hllP          // 用于计算前导零的位数
+	hllRegisters = 1 << hllP          // 寄存器个数
+	hllPMask     = hllRegisters - 1   // 计算寄存器索引的掩码
+	hllBits      = 6                  // 每个寄存器的位数
+	hllRegMax    = (1 << hllBits) - 1 // 寄存器的最大值
+	hllHdrSize   = 16                 // 头部大小
+	hllDenseSize = hllHdrSize + (hllRegisters*hllBits+7)/8
+
+	hllDense  = 0
+	hllSparse = 1
+
+	// sparse 编码的最大字节数，超过之后转换为 dense 编码，与 Redis 的 hll-sparse-max-bytes 默认值相同
+	hllSparseMaxBytes = 3000
+
+	sparseValMaxValue = 32
+	sparseValMaxLen   = 4
+	sparseZeroMaxLen  = 64
+	sparseXZeroMaxLen = 16384
+
+	hllAlphaInf = 0.721347520444481703680 // 常数 0.5/ln(2)
+	murmurSeed  = 0xadc83b19
+)
+
+var hllMagic = []byte("HYLL")
+
+// HyperLogLog 基数估计，误差约为 0.81%
+// 内存中总是以解码后的寄存器数组表示，序列化时按照当前的编码方式输出
+type HyperLogLog struct {
+	sparse     bool
+	regs       [hllRegisters]uint8
+	card       uint64
+	cacheValid bool
+}
+
+// New 新建一个空的 HyperLogLog，使用 sparse 编码
+func New() *HyperLogLog {
+	return &HyperLogLog{sparse: true, cacheValid: true}
+}
+
+// Decode 从字节表示中解析出 HyperLogLog，格式不正确时返回 ErrInvalidHLL
+func Decode(data []byte) (*HyperLogLog, error) {
+	if len(data) < hllHdrSize || string(data[:4]) != string(hllMagic) {
+		return nil, ErrInvalidHLL
+	}
+
+	h := &HyperLogLog{}
+	switch data[4] {
+	case hllDense:
+		if len(data) != hllDenseSize {
+			return nil, ErrInvalidHLL
+		}
+		regs := data[hllHdrSize:]
+		for i := 0; i < hllRegisters; i++ {
+			h.regs[i] = denseGetRegister(regs, i)
+		}
+	case hllSparse:
+		h.sparse = true
+		if err := h.decodeSparse(data[hllHdrSize:]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidHLL
+	}
+
+	card := binary.LittleEndian.Uint64(data[8:16])
+	if card&(1<<63) == 0 {
+		h.card, h.cacheValid = card, true
+	}
+	return h, nil
+}
+
+// Bytes 返回 HyperLogLog 的字节表示
+// sparse 编码超出最大长度，或者寄存器的值超出 sparse 编码的范围时，转换为 dense 编码
+func (h *HyperLogLog) Bytes() []byte {
+	if h.sparse {
+		if data, ok := h.encodeSparse(); ok {
+			h.writeHeader(data)
+			return data
+		}
+		h.sparse = false
+	}
+
+	data := make([]byte, hllDenseSize)
+	regs := data[hllHdrSize:]
+	for i := 0; i < hllRegisters; i++ {
+		denseSetRegister(regs, i, h.regs[i])
+	}
+	h.writeHeader(data)
+	return data
+}
+
+// Add 加入一个元素，返回是否有寄存器被修改，即估计的基数是否可能发生变化
+func (h *HyperLogLog) Add(element []byte) bool {
+	index, count := patLen(element)
+	if count <= h.regs[index] {
+		return false
+	}
+
+	h.regs[index] = count
+	if count > sparseValMaxValue {
+		h.sparse = false
+	}
+	h.cacheValid = false
+	return true
+}
+
+// Count 返回估计的基数，缓存有效时直接返回缓存的值
+func (h *HyperLogLog) Count() uint64 {
+	if !h.cacheValid {
+		h.card = count(&h.regs)
+		h.cacheValid = true
+	}
+	return h.card
+}
+
+// CacheValid 返回缓存的基数是否有效
+func (h *HyperLogLog) CacheValid() bool {
+	return h.cacheValid
+}
+
+// Merge 将其他的 HyperLogLog 合并进来，每个寄存器取最大值，合并后使用 dense 编码
+func (h *HyperLogLog) Merge(others ...*HyperLogLog) {
+	for _, o := range others {
+		for i, v := range o.regs {
+			if v > h.regs[i] {
+				h.regs[i] = v
+			}
+		}
+	}
+	h.sparse = false
+	h.cacheValid = false
+}
+
+func (h *HyperLogLog) writeHeader(data []byte) {
+	copy(data, hllMagic)
+	if h.sparse {
+		data[4] = hllSparse
+	} else {
+		data[4] = hllDense
+	}
+
+	card := h.card
+	if !h.cacheValid {
+		card = 1 << 63
+	}
+	binary.LittleEndian.PutUint64(data[8:16], card)
+}
+
+func (h *HyperLogLog) decodeSparse(p []byte) error {
+	idx := 0
+	for i := 0; i < len(p); {
+		var runLen int
+		var val uint8
+		switch {
+		case p[i]&0xc0 == 0x00: // ZERO
+			runLen = int(p[i]&0x3f) + 1
+			i++
+		case p[i]&0xc0 == 0x40: // XZERO
+			if i+1 >= len(p) {
+				return ErrInvalidHLL
+			}
+			runLen = (int(p[i]&0x3f)<<8 | int(p[i+1])) + 1
+			i += 2
+		default: // VAL
+			runLen = int(p[i]&0x3) + 1
+			val = (p[i]>>2)&0x1f + 1
+			i++
+		}
+
+		if idx+runLen > hllRegisters {
+			return ErrInvalidHLL
+		}
+		for j := 0; j < runLen; j++ {
+			h.regs[idx] = val
+			idx++
+		}
+	}
+
+	if idx != hllRegisters {
+		return ErrInvalidHLL
+	}
+	return nil
+}
+
+// 按照 sparse 格式编码寄存器，超出长度限制或者寄存器的值超出范围时 ok 为 false
+func (h *HyperLogLog) encodeSparse() (data []byte, ok bool) {
+	data = make([]byte, hllHdrSize, hllHdrSize+64)
+	for i := 0; i < hllRegisters; {
+		val := h.regs[i]
+		runLen := 1
+		for i+runLen < hllRegisters && h.regs[i+runLen] == val {
+			runLen++
+		}
+		i += runLen
+
+		if val == 0 {
+			for runLen > 0 {
+				n := runLen
+				if n > sparseXZeroMaxLen {
+					n = sparseXZeroMaxLen
+				}
+				if n > sparseZeroMaxLen {
+					data = append(data, 0x40|byte((n-1)>>8), byte(n-1))
+				} else {
+					data = append(data, byte(n-1))
+				}
+				runLen -= n
+			}
+			continue
+		}
+
+		if val > sparseValMaxValue {
+			return nil, false
+		}
+		for runLen > 0 {
+			n := runLen
+			if n > sparseValMaxLen {
+				n = sparseValMaxLen
+			}
+			data = append(data, 0x80|(val-1)<<2|byte(n-1))
+			runLen -= n
+		}
+
+		if len(data)-hllHdrSize > hllSparseMaxBytes {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// 读取 dense 编码中第 i 个寄存器的值
+func denseGetRegister(regs []byte, i int) uint8 {
+	byteIdx := i * hllBits / 8
+	fb := uint(i * hllBits & 7)
+	v := uint(regs[byteIdx]) >> fb
+	if byteIdx+1 < len(regs) {
+		v |= uint(regs[byteIdx+1]) << (8 - fb)
+	}
+	return uint8(v & hllRegMax)
+}
+
+// 设置 dense 编码中第 i 个寄存器的值
+func denseSetRegister(regs []byte, i int, val uint8) {
+	byteIdx := i * hllBits / 8
+	fb := uint(i * hllBits & 7)
+	regs[byteIdx] &^= byte(hllRegMax << fb)
+	regs[byteIdx] |= byte(uint(val) << fb)
+	if byteIdx+1 < len(regs) {
+		regs[byteIdx+1] &^= byte(hllRegMax >> (8 - fb))
+		regs[byteIdx+1] |= byte(uint(val) >> (8 - fb))
+	}
+}
+
+// 计算元素对应的寄存器索引，以及哈希值中从第 P 位开始的 "000..1" 模式的长度
+func patLen(element []byte) (index int, count uint8) {
+	hash := murmurHash64A(element, murmurSeed)
+	index = int(hash & hllPMask)
+	hash >>= hllP
+	hash |= 1 << hllQ // 保证循环能够结束
+	count = 1
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return
+}
+
+// 使用 Otmar Ertl 提出的改进估计算法计算基数，与 Redis 相同
+func count(regs *[hllRegisters]uint8) uint64 {
+	var histo [64]int
+	for _, v := range regs {
+		histo[v]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * tau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histo[0])/m)
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			break
+		}
+	}
+	return z / 3
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			break
+		}
+	}
+	return z
+}
+
+// MurmurHash2 的64位版本，与 Redis 中的 MurmurHash64A 相同
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(key))*m
+	data := key
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+
+	switch len(data) {
+	case 7:
+		h ^= uint64(data[6]) << 48
+		fallthrough
+	case 6:
+		h ^= uint64(data[5]) << 40
+		fallthrough
+	case 5:
+		h ^= uint64(data[4]) << 32
+		fallthrough
+	case 4:
+		h ^= uint64(data[3]) << 24
+		fallthrough
+	case 3:
+		h ^= uint64(data[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint64(data[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint64(data[0])
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
@@ -0,0 +1,128 @@
+package hll
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+// 与 Redis 中空的 HyperLogLog 相同的字节表示：sparse 编码，缓存的基数为 0，一个 XZERO 覆盖全部寄存器
+var redisEmpty = []byte("HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff")
+
+func addN(h *HyperLogLog, prefix string, n int) {
+	for i := 0; i < n; i++ {
+		h.Add([]byte(prefix + strconv.Itoa(i)))
+	}
+}
+
+func roundTrip(t *testing.T, data []byte) *HyperLogLog {
+	t.Helper()
+	h, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := h.Bytes(); !bytes.Equal(got, data) {
+		t.Fatalf("Decode then Bytes changed the encoding:\n got %x\nwant %x", got, data)
+	}
+	return h
+}
+
+func TestEmpty(t *testing.T) {
+	if got := New().Bytes(); !bytes.Equal(got, redisEmpty) {
+		t.Fatalf("New().Bytes() = %x, want %x", got, redisEmpty)
+	}
+	h := roundTrip(t, redisEmpty)
+	if !h.CacheValid() || h.Count() != 0 {
+		t.Fatalf("Count = %d, CacheValid = %v", h.Count(), h.CacheValid())
+	}
+}
+
+func TestSparseRoundTrip(t *testing.T) {
+	h := New()
+	addN(h, "a", 100)
+	n := h.Count()
+	if n < 95 || n > 105 {
+		t.Fatalf("Count = %d, want about 100", n)
+	}
+
+	data := h.Bytes()
+	if string(data[:4]) != "HYLL" || data[4] != hllSparse {
+		t.Fatalf("header = %x, want sparse", data[:hllHdrSize])
+	}
+	if len(data) >= hllDenseSize {
+		t.Fatalf("sparse encoding is %d bytes", len(data))
+	}
+
+	d := roundTrip(t, data)
+	if !d.CacheValid() || d.Count() != n {
+		t.Fatalf("decoded Count = %d, want %d", d.Count(), n)
+	}
+	if d.Add([]byte("a0")) {
+		t.Fatal("adding an existing element should not modify any register")
+	}
+}
+
+func TestDenseRoundTrip(t *testing.T) {
+	h := New()
+	addN(h, "b", 20000)
+	data := h.Bytes()
+	if len(data) != hllDenseSize || data[4] != hllDense {
+		t.Fatalf("len = %d, encoding = %d, want dense", len(data), data[4])
+	}
+
+	d := roundTrip(t, data)
+	n := d.Count()
+	if n < 19600 || n > 20400 {
+		t.Fatalf("Count = %d, want about 20000", n)
+	}
+
+	// 缓存失效时头部的最高位为 1，解码后重新计算基数
+	d.Add([]byte("not-yet-added"))
+	data = d.Bytes()
+	if data[15]&0x80 == 0 {
+		t.Fatalf("header = %x, want the cached cardinality to be invalid", data[:hllHdrSize])
+	}
+	d = roundTrip(t, data)
+	if d.CacheValid() {
+		t.Fatal("decoded cache should be invalid")
+	}
+	if c := d.Count(); c < n {
+		t.Fatalf("Count = %d, want at least %d", c, n)
+	}
+}
+
+func TestMergeIsDense(t *testing.T) {
+	a, b := New(), New()
+	addN(a, "x", 50)
+	addN(b, "y", 50)
+	a.Merge(b)
+
+	data := a.Bytes()
+	if data[4] != hllDense {
+		t.Fatalf("encoding = %d, want dense after Merge", data[4])
+	}
+	if n := roundTrip(t, data).Count(); n < 95 || n > 105 {
+		t.Fatalf("Count = %d, want about 100", n)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	dense := New()
+	dense.Merge()
+	denseData := dense.Bytes()
+
+	cases := map[string][]byte{
+		"short":           redisEmpty[:10],
+		"magic":           append([]byte("HYLX"), redisEmpty[4:]...),
+		"encoding":        append(append([]byte{}, redisEmpty[:4]...), append([]byte{2}, redisEmpty[5:]...)...),
+		"truncated xzero": redisEmpty[:len(redisEmpty)-1],
+		"too few regs":    append(append([]byte{}, redisEmpty[:hllHdrSize]...), 0x3f),
+		"too many regs":   append(append([]byte{}, redisEmpty...), 0x00),
+		"dense length":    denseData[:len(denseData)-1],
+	}
+	for name, data := range cases {
+		if _, err := Decode(data); err != ErrInvalidHLL {
+			t.Errorf("%s: err = %v, want ErrInvalidHLL", name, err)
+		}
+	}
+}
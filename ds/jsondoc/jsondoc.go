@@ -0,0 +1,363 @@
+package jsondoc
+
+import (
+	"KV_Storage/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidJSON     = errors.New("jsondoc: invalid json value")
+	ErrInvalidPath     = errors.New("jsondoc: invalid path")
+	ErrPathNotExist    = errors.New("jsondoc: path does not exist")
+	ErrNewAtRoot       = errors.New("jsondoc: new documents must be created at the root")
+	ErrNotNumber       = errors.New("jsondoc: value at path is not a number")
+	ErrNumberOverflow  = errors.New("jsondoc: increment would produce an invalid number")
+	ErrIndexOutOfRange = errors.New("jsondoc: array index out of range")
+)
+
+type (
+	// JSON JSON文档的索引，每个key对应一个解析后的文档
+	// 文档中的对象为 map[string]interface{}，数组为 []interface{}，数字为 json.Number
+	JSON struct {
+		record Record
+		keys   *utils.OrderedKeys // 所有文档的key，用于基于游标的迭代
+	}
+
+	// Record json record to save
+	Record map[string]interface{}
+
+	// Path 解析后的路径，为空时表示文档的根
+	Path []PathElem
+
+	// PathElem 路径中的一级，IsIndex 为 true 时表示数组下标，否则表示对象的成员
+	PathElem struct {
+		Key     string
+		Index   int
+		IsIndex bool
+	}
+)
+
+// New new a json idx
+func New() *JSON {
+	return &JSON{record: make(Record), keys: utils.NewOrderedKeys()}
+}
+
+// ParsePath 解析 JSONPath 风格的路径，支持 $、$.a.b、$.a[0]、$["a b"] 以及负数下标
+// 也兼容省略 $ 的写法，如 .、.a.b、a.b
+func ParsePath(s string) (Path, error) {
+	switch {
+	case s == "$" || s == ".":
+		return Path{}, nil
+	case strings.HasPrefix(s, "$"):
+		s = s[1:]
+	case !strings.HasPrefix(s, ".") && !strings.HasPrefix(s, "["):
+		s = "." + s
+	}
+
+	path := Path{}
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			end := strings.IndexAny(s[1:], ".[")
+			if end < 0 {
+				end = len(s) - 1
+			}
+			if end == 0 {
+				return nil, ErrInvalidPath
+			}
+			path = append(path, PathElem{Key: s[1 : end+1]})
+			s = s[end+1:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, ErrInvalidPath
+			}
+			elem, rest, err := parseBracket(s[1:])
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, elem)
+			s = rest
+		default:
+			return nil, ErrInvalidPath
+		}
+	}
+	return path, nil
+}
+
+// 解析 [ 之后的内容，可以是带引号的成员名或者整数下标，返回 ] 之后剩余的部分
+func parseBracket(s string) (elem PathElem, rest string, err error) {
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		quote := s[0]
+		var key strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch {
+			case s[i] == '\\' && i+1 < len(s):
+				i++
+				key.WriteByte(s[i])
+			case s[i] == quote:
+				if i+1 >= len(s) || s[i+1] != ']' {
+					return elem, "", ErrInvalidPath
+				}
+				return PathElem{Key: key.String()}, s[i+2:], nil
+			default:
+				key.WriteByte(s[i])
+			}
+		}
+		return elem, "", ErrInvalidPath
+	}
+
+	end := strings.IndexByte(s, ']')
+	index, err := strconv.Atoi(strings.TrimSpace(s[:end]))
+	if err != nil {
+		return elem, "", ErrInvalidPath
+	}
+	return PathElem{Index: index, IsIndex: true}, s[end+1:], nil
+}
+
+// Unmarshal 解析一个完整的JSON值，数字解析为 json.Number 以保留其原始形式
+func Unmarshal(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, ErrInvalidJSON
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, ErrInvalidJSON
+	}
+	return v, nil
+}
+
+// Marshal 将JSON值编码为紧凑的形式，对象的成员按名称排序
+func Marshal(v interface{}) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// Get 返回文档 key 中 path 处的值
+func (j *JSON) Get(key string, path Path) (interface{}, bool) {
+	doc, ok := j.record[key]
+	if !ok {
+		return nil, false
+	}
+	return resolve(doc, path)
+}
+
+// CanSet 检查能否将文档 key 中 path 处的值设置为新值，即文档不存在时 path 必须为根，否则 path 的上一级必须存在
+// 且为对象或数组，为数组时下标必须在范围内
+func (j *JSON) CanSet(key string, path Path) error {
+	doc, ok := j.record[key]
+	if len(path) == 0 {
+		return nil
+	}
+	if !ok {
+		return ErrNewAtRoot
+	}
+
+	parent, ok := resolve(doc, path[:len(path)-1])
+	if !ok {
+		return ErrPathNotExist
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if last.IsIndex {
+			return ErrPathNotExist
+		}
+	case []interface{}:
+		if !last.IsIndex {
+			return ErrPathNotExist
+		}
+		if _, ok := arrayIndex(p, last.Index); !ok {
+			return ErrIndexOutOfRange
+		}
+	default:
+		return ErrPathNotExist
+	}
+	return nil
+}
+
+// Set 将文档 key 中 path 处的值设置为 value，path 为根时替换整个文档
+// 对象中不存在的成员会被新建，数组只能替换已有的元素
+func (j *JSON) Set(key string, path Path, value interface{}) error {
+	if err := j.CanSet(key, path); err != nil {
+		return err
+	}
+	if len(path) == 0 {
+		j.record[key] = value
+		j.keys.Add(key)
+		return nil
+	}
+
+	parent, _ := resolve(j.record[key], path[:len(path)-1])
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last.Key] = value
+	case []interface{}:
+		i, _ := arrayIndex(p, last.Index)
+		p[i] = value
+	}
+	return nil
+}
+
+// Del 删除文档 key 中 path 处的值，path 为根时删除整个文档，返回值是否存在
+func (j *JSON) Del(key string, path Path) bool {
+	doc, ok := j.record[key]
+	if !ok {
+		return false
+	}
+	if len(path) == 0 {
+		j.Clear(key)
+		return true
+	}
+
+	parent, ok := resolve(doc, path[:len(path)-1])
+	if !ok {
+		return false
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := p[last.Key]; !ok || last.IsIndex {
+			return false
+		}
+		delete(p, last.Key)
+		return true
+	case []interface{}:
+		i, ok := arrayIndex(p, last.Index)
+		if !ok || !last.IsIndex {
+			return false
+		}
+		// 数组在父节点中是按值保存的，需要将缩短后的数组写回
+		return j.Set(key, path[:len(path)-1], append(p[:i:i], p[i+1:]...)) == nil
+	}
+	return false
+}
+
+// NumIncrBy 计算文档 key 中 path 处的数字加上 by 之后的值，并不修改文档
+// 两者都是整数且结果不溢出时结果为整数，否则为浮点数
+func (j *JSON) NumIncrBy(key string, path Path, by json.Number) (json.Number, error) {
+	v, ok := j.Get(key, path)
+	if !ok {
+		return "", ErrPathNotExist
+	}
+	num, ok := v.(json.Number)
+	if !ok {
+		return "", ErrNotNumber
+	}
+
+	x, errX := num.Int64()
+	y, errY := by.Int64()
+	if errX == nil && errY == nil {
+		sum := new(big.Int).Add(big.NewInt(x), big.NewInt(y))
+		if sum.IsInt64() {
+			return json.Number(sum.String()), nil
+		}
+	}
+
+	fx, errX := num.Float64()
+	fy, errY := by.Float64()
+	if errX != nil || errY != nil {
+		return "", ErrNotNumber
+	}
+	res := fx + fy
+	if math.IsNaN(res) || math.IsInf(res, 0) {
+		return "", ErrNumberOverflow
+	}
+	if math.Abs(res) >= 1e21 {
+		return json.Number(strconv.FormatFloat(res, 'g', -1, 64)), nil
+	}
+	return json.Number(strconv.FormatFloat(res, 'f', -1, 64)), nil
+}
+
+// Clear 删除文档 key
+func (j *JSON) Clear(key string) {
+	delete(j.record, key)
+	j.keys.Remove(key)
+}
+
+// Exists 判断文档 key 是否存在
+func (j *JSON) Exists(key string) bool {
+	_, exist := j.record[key]
+	return exist
+}
+
+// KeyCount 返回所有文档的key的个数
+func (j *JSON) KeyCount() int {
+	return j.keys.Len()
+}
+
+// Keys 返回所有文档的key
+func (j *JSON) Keys() (keys []string) {
+	for k := range j.record {
+		keys = append(keys, k)
+	}
+	return
+}
+
+// Rename 将文档 key 转移到 newKey 下，newKey 原有的数据会被覆盖，key 不存在时不做任何操作
+func (j *JSON) Rename(key, newKey string) {
+	if v, ok := j.record[key]; ok {
+		j.Clear(key)
+		j.record[newKey] = v
+		j.keys.Add(newKey)
+	}
+}
+
+// ScanKeys 按字典序依次将文档的key交给 fn，直到 fn 返回 false，用于基于游标的迭代
+// bounded 为 false 时从第一个key开始，否则从第一个大于 after 的key开始
+func (j *JSON) ScanKeys(after string, bounded bool, fn func(key string) bool) {
+	j.keys.Ascend(after, bounded, fn)
+}
+
+// 沿着 path 查找 v 中的值
+func resolve(v interface{}, path Path) (interface{}, bool) {
+	for _, elem := range path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			if elem.IsIndex {
+				return nil, false
+			}
+			child, ok := node[elem.Key]
+			if !ok {
+				return nil, false
+			}
+			v = child
+		case []interface{}:
+			if !elem.IsIndex {
+				return nil, false
+			}
+			i, ok := arrayIndex(node, elem.Index)
+			if !ok {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// 将可能为负数的下标转换为数组中的实际位置
+func arrayIndex(arr []interface{}, index int) (int, bool) {
+	if index < 0 {
+		index += len(arr)
+	}
+	return index, index >= 0 && index < len(arr)
+}
@@ -1,8 +1,8 @@
 package list
 
 import (
-	"container/list"
-	"reflect"
+	"KV_Storage/utils"
+	"bytes"
 )
 
 type InsertOption uint8
@@ -12,26 +12,32 @@ const (
 	After
 )
 
-var existFlag = struct {
-}{}
+// 环形缓冲区的最小容量
+const minDequeCap = 8
 
 type (
+	// List 列表的索引，每个列表保存在一个基于环形缓冲区的双端队列中
+	// 两端的加入和取出均摊为 O(1)，按下标访问为 O(1)，在中间插入或删除需要移动较短一侧的元素
 	List struct {
 		record Record
-		values map[string]map[string]struct{}
+		keys   *utils.OrderedKeys // 所有非空列表的key，用于基于游标的迭代
+	}
+	Record map[string]*deque
+
+	// deque 基于环形缓冲区的双端队列，元素个数为0的队列不会保存在 record 中
+	deque struct {
+		buf  [][]byte // 容量总是2的幂
+		head int      // 第一个元素在 buf 中的位置
+		size int
 	}
-	Record map[string]*list.List
 )
 
 func New() *List {
-	return &List{
-		make(Record),
-		make(map[string]map[string]struct{}),
-	}
+	return &List{record: make(Record), keys: utils.NewOrderedKeys()}
 }
 
-func (l *List) LPush(key string, val ...[]byte) int {
-	return l.push(true, key, val...)
+func (lis *List) LPush(key string, val ...[]byte) int {
+	return lis.push(true, key, val...)
 }
 
 // LPop 取出列表头部的元素
@@ -54,28 +60,81 @@ func (lis *List) LKeyExists(key string) (ok bool) {
 	_, ok = lis.record[key]
 	return
 }
-func (l *List) LValExists(key string, val []byte) (ok bool) {
-	if l.values[key] != nil {
-		_, ok = l.values[key][string(val)]
+
+// LValExists check if the val exists in a specified List stored at key.
+func (lis *List) LValExists(key string, val []byte) bool {
+	return lis.find(key, val) >= 0
+}
+
+// LClear 删除整个列表
+func (lis *List) LClear(key string) {
+	lis.remove(key)
+}
+
+// KeyCount 返回所有非空列表的key的个数
+func (lis *List) KeyCount() int {
+	return lis.keys.Len()
+}
+
+// Keys 返回所有非空列表的key
+func (lis *List) Keys() (keys []string) {
+	for k := range lis.record {
+		keys = append(keys, k)
+	}
+	return
+}
+
+// Rename 将列表 key 转移到 newKey 下，newKey 原有的数据会被覆盖，key 不存在时不做任何操作
+func (lis *List) Rename(key, newKey string) {
+	if v, ok := lis.record[key]; ok {
+		lis.remove(key)
+		lis.record[newKey] = v
+		lis.keys.Add(newKey)
+	}
+}
+
+// ScanKeys 按字典序依次将非空列表的key交给 fn，直到 fn 返回 false，用于基于游标的迭代
+// bounded 为 false 时从第一个key开始，否则从第一个大于 after 的key开始
+func (lis *List) ScanKeys(after string, bounded bool, fn func(key string) bool) {
+	lis.keys.Ascend(after, bounded, fn)
+}
+
+// LPos 返回列表中与 val 相等的元素的下标
+// rank 为正数时从表头开始查找，跳过前 rank-1 个匹配的元素；为负数时从表尾开始查找，跳过前 -rank-1 个匹配的元素
+// count 为0时返回所有匹配的下标，否则最多返回 count 个；maxLen 大于0时最多比较 maxLen 个元素
+func (lis *List) LPos(key string, val []byte, rank, count, maxLen int) (res []int) {
+	d := lis.record[key]
+	if d == nil || rank == 0 {
+		return
+	}
+
+	skip, i, step := rank-1, 0, 1
+	if rank < 0 {
+		skip, i, step = -rank-1, d.size-1, -1
+	}
+	for compared := 0; i >= 0 && i < d.size && (maxLen <= 0 || compared < maxLen); i, compared = i+step, compared+1 {
+		if !bytes.Equal(d.at(i), val) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		res = append(res, i)
+		if count > 0 && len(res) == count {
+			break
+		}
 	}
 	return
 }
 
 // LIndex 返回列表在index处的值，如果不存在则返回nil
 func (lis *List) LIndex(key string, index int) []byte {
-	ok, newIndex := lis.validIndex(key, index)
+	ok, index := lis.validIndex(key, index)
 	if !ok {
 		return nil
 	}
-
-	index = newIndex
-	var val []byte
-	e := lis.index(key, index)
-	if e != nil {
-		val = e.Value.([]byte) // 取出element对应的value并返回
-	}
-
-	return val
+	return lis.record[key].at(index)
 }
 
 // LRem 根据参数 count 的值，移除列表中与参数 value 相等的元素
@@ -84,280 +143,189 @@ func (lis *List) LIndex(key string, index int) []byte {
 // count = 0 : 移除列表中所有与 value 相等的值
 // 返回成功删除的元素个数
 func (lis *List) LRem(key string, val []byte, count int) int {
-	item := lis.record[key] // 拿到key对应的list
-	if item == nil {
+	d := lis.record[key] // 拿到key对应的list
+	if d == nil {
 		return 0
 	}
 
-	var ele []*list.Element
-	if count == 0 { // 删除所有与val相等的值
-		for p := item.Front(); p != nil; p = p.Next() {
-			if reflect.DeepEqual(p.Value.([]byte), val) { // 将与val相等的值加入到ele切片中
-				ele = append(ele, p)
-			}
-		}
+	limit := count
+	if count < 0 {
+		limit = -count
 	}
 
-	if count > 0 { // 从前到后删除count个与val相等的值
-		for p := item.Front(); p != nil && len(ele) < count; p = p.Next() {
-			if reflect.DeepEqual(p.Value.([]byte), val) {
-				ele = append(ele, p)
-			}
+	// 先标记要删除的元素，再一次性地将保留的元素向前移动
+	removed := make([]bool, d.size)
+	n := 0
+	for i := 0; i < d.size && (limit == 0 || n < limit); i++ {
+		j := i
+		if count < 0 {
+			j = d.size - 1 - i
 		}
-	}
-
-	if count < 0 { // 从后到前删除count个与val相等的值
-		for p := item.Back(); p != nil && len(ele) < -count; p = p.Prev() {
-			if reflect.DeepEqual(p.Value.([]byte), val) {
-				ele = append(ele, p)
-			}
+		if bytes.Equal(d.at(j), val) {
+			removed[j] = true
+			n++
 		}
 	}
-
-	for _, e := range ele { // 遍历ele切片挨个删除
-		item.Remove(e)
+	if n == 0 {
+		return 0
 	}
 
-	length := len(ele)
-	ele = nil // ele切片置空 （目的是啥子？）
-
-	if lis.values[key] != nil {
-		delete(lis.values[key], string(val))
+	w := 0
+	for r := 0; r < d.size; r++ {
+		if !removed[r] {
+			d.set(w, d.at(r))
+			w++
+		}
 	}
-
-	return length
+	d.truncate(w)
+	lis.deleteIfEmpty(key)
+	return n
 }
 
 // LInsert 将值 val 插入到列表 key 当中，位于值 pivot 之前或之后
 // 如果命令执行成功，返回插入操作完成之后，列表的长度。 如果没有找到 pivot ，返回 -1
 func (lis *List) LInsert(key string, option InsertOption, pivot, val []byte) int {
-	e := lis.find(key, pivot) // 找到相应的element
-	if e == nil {             // 如果不存在就返回
+	i := lis.find(key, pivot) // 找到第一个与pivot相等的元素
+	if i < 0 {
 		return -1
 	}
 
-	item := lis.record[key]
-	if option == Before {
-		item.InsertBefore(val, e) // 调用list自带的库函数在element前插入val
-	}
 	if option == After {
-		item.InsertAfter(val, e)
-	}
-
-	if lis.values[key] == nil {
-		lis.values[key] = make(map[string]struct{})
+		i++
 	}
-	lis.values[key][string(val)] = existFlag
-
-	return item.Len()
+	d := lis.record[key]
+	d.insert(i, val)
+	return d.size
 }
 
-func (l *List) LSet(key string, index int, val []byte) bool {
-	e := l.index(key, index)
-	if e == nil {
+// LSet 将列表 key 下标为 index 的元素的值设置为 val，index 可以为负数，下标不存在时返回 false
+// 改为环形缓冲区之前负数下标总是返回 false，因此写入日志的调用方应当记录转换后的非负下标
+func (lis *List) LSet(key string, index int, val []byte) bool {
+	ok, index := lis.validIndex(key, index)
+	if !ok {
 		return false
 	}
-	if l.values != nil {
-		l.values[key] = make(map[string]struct{})
-	}
-	if e.Value != nil {
-		delete(l.values[key], string(e.Value.([]byte)))
-	}
-	e.Value = val
-	l.values[key][string(val)] = existFlag
-
+	lis.record[key].set(index, val)
 	return true
 }
-func (l *List) LRange(key string, start, end int) [][]byte {
-	var val [][]byte
-	item := l.record[key]
 
-	if item == nil || item.Len() <= 0 {
+// LRange 返回列表 key 中指定区间内的元素，区间以偏移量 start 和 end 指定，可以为负数
+func (lis *List) LRange(key string, start, end int) [][]byte {
+	var val [][]byte
+	d := lis.record[key]
+	if d == nil {
 		return val
 	}
-	length := item.Len()
-	start, end = l.handleIndex(length, start, end)
-	if start > end || start >= length {
+
+	start, end = lis.handleIndex(d.size, start, end)
+	if start > end || start >= d.size {
 		return val
 	}
 
-	mid := length >> 1
-	if end <= mid || end-mid < mid-start {
-		flag := 0
-		for p := item.Front(); p != nil && flag <= end; p, flag = p.Next(), flag+1 {
-			if flag >= start {
-				val = append(val, p.Value.([]byte))
-			}
-		}
-	} else {
-		flag := length - 1
-		for p := item.Back(); p != nil && flag >= start; p, flag = p.Prev(), flag-1 {
-			if flag <= end {
-				val = append(val, p.Value.([]byte))
-			}
-		}
-		if len(val) > 0 { // 从后往前遍历注意要将结果集反转下
-			for i, j := 0, len(val)-1; i < j; i, j = i+1, j-1 {
-				val[i], val[j] = val[j], val[i]
-			}
-		}
+	val = make([][]byte, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		val = append(val, d.at(i))
 	}
 	return val
 }
 
-func (l *List) LTrim(key string, start, end int) bool {
-	item := l.record[key]
-	l.values[key] = nil
-	if item == nil || item.Len() <= 0 {
+// LTrim 让列表只保留区间 [start, end] 内的元素，区间为空时删除整个列表，列表被修改时返回 true
+func (lis *List) LTrim(key string, start, end int) bool {
+	d := lis.record[key]
+	if d == nil {
 		return false
 	}
-	length := item.Len()
-	start, end = l.handleIndex(length, start, end)
 
-	if start <= 0 && end >= length {
+	length := d.size
+	start, end = lis.handleIndex(length, start, end)
+	if start <= 0 && end >= length-1 {
 		return false
 	}
 	if start > end || start >= length {
-		l.record[key] = nil
+		lis.remove(key)
 		return true
 	}
-	startEle, endEle := l.index(key, start), l.index(key, end)
-	if end-start+1 < (length >> 1) {
-		newList := list.New()
-		newValueMap := make(map[string]struct{})
-		for p := startEle; p != endEle.Next(); p = p.Next() {
-			newList.PushBack(p.Value)
-			if p.Value != nil {
-				newValueMap[string(p.Value.([]byte))] = existFlag
-			}
-		}
-		item = nil
-		l.record[key] = newList
-		l.values[key] = newValueMap
-	} else {
-		var ele []*list.Element
-		for p := item.Front(); p != startEle; p = p.Next() {
-			ele = append(ele, p)
-		}
-		for p := item.Back(); p != endEle; p = p.Prev() {
-			ele = append(ele, p)
-		}
-		for _, e := range ele {
-			item.Remove(e)
-			if l.values[key] != nil && e.Value != nil {
-				delete(l.values[key], string(e.Value.([]byte)))
-			}
-		}
-		ele = nil
-	}
+
+	d.trim(start, end-start+1)
 	return true
 }
 
-func (l *List) find(key string, val []byte) *list.Element {
-	item := l.record[key]
-	var e *list.Element
-
-	if item != nil {
-		for p := item.Front(); p != nil; p = p.Next() {
-			if reflect.DeepEqual(p.Value.([]byte), val) {
-				e = p
-				break
-			}
-
-		}
+// LLen 返回指定key的列表中的元素个数
+func (lis *List) LLen(key string) int {
+	if d := lis.record[key]; d != nil {
+		return d.size
 	}
-	return e
+	return 0
 }
 
-func (l *List) index(key string, index int) *list.Element {
-	ok, newIndex := l.validIndex(key, index)
-	if !ok {
-		return nil
+// 返回列表中第一个与 val 相等的元素的下标，不存在时返回-1
+func (lis *List) find(key string, val []byte) int {
+	d := lis.record[key]
+	if d == nil {
+		return -1
 	}
-	index = newIndex
-	item := l.record[key]
-	var e *list.Element
-
-	if item != nil && item.Len() > 0 {
-		if index <= (item.Len() >> 1) {
-			val := item.Front()
-			for i := 0; i < index; i++ {
-				val = val.Next()
-			}
-			e = val
-		} else {
-			val := item.Back()
-			for i := item.Len() - 1; i > index; i-- {
-				val = val.Prev()
-			}
-			e = val
+	for i := 0; i < d.size; i++ {
+		if bytes.Equal(d.at(i), val) {
+			return i
 		}
 	}
-	return e
-
+	return -1
 }
 
-func (l *List) push(front bool, key string, val ...[]byte) int {
-
-	if l.record[key] == nil {
-		l.record[key] = list.New()
-	}
-	if l.values[key] == nil {
-		l.values[key] = make(map[string]struct{})
+func (lis *List) push(front bool, key string, val ...[]byte) int {
+	d := lis.record[key]
+	if d == nil {
+		d = &deque{}
+		lis.record[key] = d
+		lis.keys.Add(key)
 	}
 	for _, n := range val {
 		if front {
-			l.record[key].PushFront(n)
+			d.pushFront(n)
 		} else {
-			l.record[key].PushBack(n)
+			d.pushBack(n)
 		}
-		l.values[key][string(n)] = existFlag
 	}
-	return l.record[key].Len()
-
+	return d.size
 }
 
-// LLen 返回指定key的列表中的元素个数
-func (lis *List) LLen(key string) int {
-	length := 0
-	if lis.record[key] != nil {
-		length = lis.record[key].Len()
+func (lis *List) pop(front bool, key string) []byte {
+	d := lis.record[key]
+	if d == nil {
+		return nil
 	}
 
-	return length
-}
-func (l *List) pop(front bool, key string) []byte {
-	item := l.record[key]
 	var val []byte
-	if item != nil && item.Len() > 0 {
-		var e *list.Element
-		if front {
-			e = item.Front()
-		} else {
-			e = item.Back()
-		}
-		val = e.Value.([]byte)
-		item.Remove(e)
-		if l.values[key] != nil {
-			delete(l.values[key], string(val))
-		}
+	if front {
+		val = d.popFront()
+	} else {
+		val = d.popBack()
 	}
+	lis.deleteIfEmpty(key)
 	return val
 }
 
-func (l *List) validIndex(key string, index int) (bool, int) {
-	item := l.record[key]
-	if item == nil || item.Len() <= 0 {
-		return false, index
+func (lis *List) deleteIfEmpty(key string) {
+	if d := lis.record[key]; d != nil && d.size == 0 {
+		lis.remove(key)
 	}
-	length := item.Len()
-	if length < 0 {
+}
+
+func (lis *List) remove(key string) {
+	delete(lis.record, key)
+	lis.keys.Remove(key)
+}
+
+// 检查下标是否在列表范围内，负数表示从表尾开始计算，返回转换后的非负下标
+func (lis *List) validIndex(key string, index int) (bool, int) {
+	length := lis.LLen(key)
+	if index < 0 {
 		index += length
 	}
 	return index >= 0 && index < length, index
 }
 
-func (l *List) handleIndex(length, start, end int) (int, int) {
+func (lis *List) handleIndex(length, start, end int) (int, int) {
 	if start < 0 {
 		start += length
 	}
@@ -372,3 +340,109 @@ func (l *List) handleIndex(length, start, end int) (int, int) {
 	}
 	return start, end
 }
+
+// 返回下标为 i 的元素，调用方需保证 0 <= i < size
+func (d *deque) at(i int) []byte {
+	return d.buf[(d.head+i)&(len(d.buf)-1)]
+}
+
+func (d *deque) set(i int, val []byte) {
+	d.buf[(d.head+i)&(len(d.buf)-1)] = val
+}
+
+func (d *deque) pushFront(val []byte) {
+	d.grow()
+	d.head = (d.head - 1) & (len(d.buf) - 1)
+	d.buf[d.head] = val
+	d.size++
+}
+
+func (d *deque) pushBack(val []byte) {
+	d.grow()
+	d.size++
+	d.set(d.size-1, val)
+}
+
+func (d *deque) popFront() []byte {
+	if d.size == 0 {
+		return nil
+	}
+	val := d.buf[d.head]
+	d.buf[d.head] = nil
+	d.head = (d.head + 1) & (len(d.buf) - 1)
+	d.size--
+	d.shrink()
+	return val
+}
+
+func (d *deque) popBack() []byte {
+	if d.size == 0 {
+		return nil
+	}
+	val := d.at(d.size - 1)
+	d.set(d.size-1, nil)
+	d.size--
+	d.shrink()
+	return val
+}
+
+// 在下标 i 处插入元素，移动 i 两侧中较短的一侧
+func (d *deque) insert(i int, val []byte) {
+	if i < d.size-i {
+		d.pushFront(nil)
+		for j := 0; j < i; j++ {
+			d.set(j, d.at(j+1))
+		}
+	} else {
+		d.pushBack(nil)
+		for j := d.size - 1; j > i; j-- {
+			d.set(j, d.at(j-1))
+		}
+	}
+	d.set(i, val)
+}
+
+// 只保留从下标 start 开始的 n 个元素
+func (d *deque) trim(start, n int) {
+	for i := 0; i < start; i++ {
+		d.set(i, nil)
+	}
+	for i := start + n; i < d.size; i++ {
+		d.set(i, nil)
+	}
+	d.head = (d.head + start) & (len(d.buf) - 1)
+	d.size = n
+	d.shrink()
+}
+
+// 只保留前 n 个元素
+func (d *deque) truncate(n int) {
+	d.trim(0, n)
+}
+
+// 缓冲区已满时将容量翻倍
+func (d *deque) grow() {
+	if d.size < len(d.buf) {
+		return
+	}
+	newCap := len(d.buf) * 2
+	if newCap < minDequeCap {
+		newCap = minDequeCap
+	}
+	d.resize(newCap)
+}
+
+// 元素个数不足容量的四分之一时将容量减半，避免删除大量元素后仍占用内存
+func (d *deque) shrink() {
+	if len(d.buf) > minDequeCap && d.size <= len(d.buf)/4 {
+		d.resize(len(d.buf) / 2)
+	}
+}
+
+func (d *deque) resize(newCap int) {
+	buf := make([][]byte, newCap)
+	for i := 0; i < d.size; i++ {
+		buf[i] = d.at(i)
+	}
+	d.buf, d.head = buf, 0
+}
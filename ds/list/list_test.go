@@ -0,0 +1,159 @@
+package list
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+func vals(n int) [][]byte {
+	res := make([][]byte, n)
+	for i := range res {
+		res[i] = []byte(strconv.Itoa(i))
+	}
+	return res
+}
+
+func assertRange(t *testing.T, lis *List, key string, want ...string) {
+	t.Helper()
+	got := lis.LRange(key, 0, -1)
+	if len(got) != len(want) {
+		t.Fatalf("LRange(%q) = %q, want %q", key, got, want)
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Fatalf("LRange(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestDequeWrapAround(t *testing.T) {
+	lis := New()
+	// 先从头部加入，使 head 回绕到缓冲区的末尾
+	lis.LPush("k", []byte("b"), []byte("a"))
+	lis.RPush("k", []byte("c"), []byte("d"))
+	d := lis.record["k"]
+	if d.head+d.size <= len(d.buf) {
+		t.Fatalf("expected the deque to wrap around, head=%d size=%d cap=%d", d.head, d.size, len(d.buf))
+	}
+	assertRange(t, lis, "k", "a", "b", "c", "d")
+
+	if v := lis.LIndex("k", 0); string(v) != "a" {
+		t.Fatalf("LIndex(0) = %q", v)
+	}
+	if v := lis.LIndex("k", -1); string(v) != "d" {
+		t.Fatalf("LIndex(-1) = %q", v)
+	}
+
+	// 缓冲区已满并且回绕时扩容，元素顺序保持不变
+	for i := 0; i < 10; i++ {
+		lis.LPush("k", []byte("x"+strconv.Itoa(i)))
+	}
+	if got := lis.LLen("k"); got != 14 {
+		t.Fatalf("LLen = %d, want 14", got)
+	}
+	if v := lis.LIndex("k", 0); string(v) != "x9" {
+		t.Fatalf("LIndex(0) after grow = %q", v)
+	}
+	if v := lis.LIndex("k", 13); string(v) != "d" {
+		t.Fatalf("LIndex(13) after grow = %q", v)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	lis := New()
+	lis.RPush("k", []byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e"))
+
+	// 靠近表头时移动前半部分，靠近表尾时移动后半部分
+	if n := lis.LInsert("k", Before, []byte("b"), []byte("x")); n != 6 {
+		t.Fatalf("LInsert before = %d", n)
+	}
+	if n := lis.LInsert("k", After, []byte("d"), []byte("y")); n != 7 {
+		t.Fatalf("LInsert after = %d", n)
+	}
+	if n := lis.LInsert("k", After, []byte("e"), []byte("z")); n != 8 {
+		t.Fatalf("LInsert at tail = %d", n)
+	}
+	if n := lis.LInsert("k", Before, []byte("a"), []byte("w")); n != 9 {
+		t.Fatalf("LInsert at head = %d", n)
+	}
+	if n := lis.LInsert("k", Before, []byte("missing"), []byte("v")); n != -1 {
+		t.Fatalf("LInsert without pivot = %d", n)
+	}
+	assertRange(t, lis, "k", "w", "a", "x", "b", "c", "d", "y", "e", "z")
+}
+
+func TestTrim(t *testing.T) {
+	lis := New()
+	lis.RPush("k", vals(10)...)
+
+	if lis.LTrim("k", 0, -1) {
+		t.Fatal("LTrim of the whole list should not modify it")
+	}
+	if !lis.LTrim("k", 2, -3) {
+		t.Fatal("LTrim should modify the list")
+	}
+	assertRange(t, lis, "k", "2", "3", "4", "5", "6", "7")
+
+	if !lis.LTrim("k", 1, 1) {
+		t.Fatal("LTrim should modify the list")
+	}
+	assertRange(t, lis, "k", "3")
+
+	if !lis.LTrim("k", 5, 10) || lis.LKeyExists("k") {
+		t.Fatal("LTrim to an empty range should delete the list")
+	}
+}
+
+func TestShrink(t *testing.T) {
+	lis := New()
+	lis.RPush("k", vals(1000)...)
+	d := lis.record["k"]
+	if len(d.buf) != 1024 {
+		t.Fatalf("cap = %d, want 1024", len(d.buf))
+	}
+
+	for i := 0; i < 990; i++ {
+		lis.LPop("k")
+	}
+	if len(d.buf) > 64 {
+		t.Fatalf("cap = %d after popping most elements, want it to shrink", len(d.buf))
+	}
+	if len(d.buf) < minDequeCap || d.size > len(d.buf) {
+		t.Fatalf("invalid cap %d for size %d", len(d.buf), d.size)
+	}
+	for i := 0; i < 10; i++ {
+		if v := lis.LIndex("k", i); !bytes.Equal(v, []byte(strconv.Itoa(990+i))) {
+			t.Fatalf("LIndex(%d) = %q", i, v)
+		}
+	}
+
+	for lis.LLen("k") > 0 {
+		lis.RPop("k")
+	}
+	if lis.LKeyExists("k") {
+		t.Fatal("popping every element should delete the list")
+	}
+}
+
+func TestRemAndSet(t *testing.T) {
+	lis := New()
+	lis.RPush("k", []byte("a"), []byte("b"), []byte("a"), []byte("c"), []byte("a"))
+
+	if n := lis.LRem("k", []byte("a"), -2); n != 2 {
+		t.Fatalf("LRem = %d", n)
+	}
+	assertRange(t, lis, "k", "a", "b", "c")
+
+	if !lis.LSet("k", -1, []byte("z")) || lis.LSet("k", 3, []byte("z")) || lis.LSet("k", -4, []byte("z")) {
+		t.Fatal("LSet index validation")
+	}
+	assertRange(t, lis, "k", "a", "b", "z")
+
+	if n := lis.LRem("k", []byte("a"), 0); n != 1 {
+		t.Fatalf("LRem = %d", n)
+	}
+	if pos := lis.LPos("k", []byte("z"), 1, 0, 0); len(pos) != 1 || pos[0] != 1 {
+		t.Fatalf("LPos = %v", pos)
+	}
+}
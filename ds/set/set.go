@@ -1,15 +1,19 @@
 package set
 
+import "KV_Storage/utils"
+
 type (
 	Set struct {
-		record Record
+		record  Record
+		keys    *utils.OrderedKeys            // 所有非空集合的key，用于基于游标的迭代
+		members map[string]*utils.OrderedKeys // 每个集合中的元素，用于基于游标的迭代
 	}
 	Record map[string]map[string]bool
 )
 
 // New new a set idx
 func New() *Set {
-	return &Set{make(Record)}
+	return &Set{record: make(Record), keys: utils.NewOrderedKeys(), members: make(map[string]*utils.OrderedKeys)}
 }
 
 // SRandMember 从集合中返回随机元素，count的可选值如下：
@@ -50,26 +54,12 @@ func (s *Set) SRandMember(key string, count int) [][]byte {
 // SRem 移除集合 key 中的一个 member 元素，不存在的 member 元素会被忽略
 // 返回是否被成功移除
 func (s *Set) SRem(key string, member []byte) bool {
-	if !s.exist(key) {
-		return false
-	}
-
-	if ok := s.record[key][string(member)]; ok {
-		delete(s.record[key], string(member))
-		return true
-	}
-
-	return false
+	return s.remove(key, string(member))
 }
 
 // SAdd 添加元素，返回添加后的集合中的元素个数
 func (s *Set) SAdd(key string, member []byte) int {
-	if !s.exist(key) {
-		s.record[key] = make(map[string]bool)
-	}
-
-	s.record[key][string(member)] = true
-
+	s.add(key, string(member))
 	return len(s.record[key])
 }
 
@@ -80,8 +70,8 @@ func (s *Set) SPop(key string, count int) [][]byte {
 		return val
 	}
 
-	for k, _ := range s.record[key] { // 遍历集合map（无序的）
-		delete(s.record[key], k)     // 从集合map中删除
+	for k := range s.record[key] { // 遍历集合map（无序的）
+		s.remove(key, k)             // 从集合中删除
 		val = append(val, []byte(k)) // 将删除的元素加入到结果集中最后返回
 
 		count--
@@ -99,16 +89,19 @@ func (s *Set) SMove(src, dst string, member []byte) bool {
 		return false
 	}
 
-	if !s.exist(dst) {
-		s.record[dst] = make(map[string]bool)
-	}
-
-	delete(s.record[src], string(member))
-	s.record[dst][string(member)] = true
+	s.remove(src, string(member))
+	s.add(dst, string(member))
 
 	return true
 }
 
+// SClear 删除整个集合
+func (s *Set) SClear(key string) {
+	delete(s.record, key)
+	delete(s.members, key)
+	s.keys.Remove(key)
+}
+
 // SCard 返回集合中的元素个数
 func (s *Set) SCard(key string) int {
 	if !s.exist(key) {
@@ -150,9 +143,67 @@ func (s *Set) SUnion(keys ...string) (val [][]byte) {
 	return
 }
 
-// 差集
+// SInter 返回给定全部集合数据的交集，任一集合不存在时交集为空
+func (s *Set) SInter(keys ...string) (val [][]byte) {
+	s.inter(keys, 0, func(member string) {
+		val = append(val, []byte(member))
+	})
+	return
+}
+
+// SInterCard 返回给定全部集合数据的交集的元素个数，limit 大于0时最多计算到 limit 个
+func (s *Set) SInterCard(limit int, keys ...string) (count int) {
+	s.inter(keys, limit, func(string) {
+		count++
+	})
+	return
+}
+
+// 从最小的集合开始遍历，对同时属于其他所有集合的元素调用 fn，limit 大于0时最多调用 limit 次
+func (s *Set) inter(keys []string, limit int, fn func(member string)) {
+	if len(keys) == 0 {
+		return
+	}
+
+	smallest := keys[0]
+	for _, k := range keys {
+		if !s.exist(k) {
+			return
+		}
+		if len(s.record[k]) < len(s.record[smallest]) {
+			smallest = k
+		}
+	}
+
+	n := 0
+	for member := range s.record[smallest] {
+		in := true
+		for _, k := range keys {
+			if k != smallest && !s.record[k][member] {
+				in = false
+				break
+			}
+		}
+		if in {
+			fn(member)
+			if n++; limit > 0 && n >= limit {
+				return
+			}
+		}
+	}
+}
+
+// SStore 用 members 替换集合 key 中的全部元素，members 为空时删除集合
+func (s *Set) SStore(key string, members [][]byte) {
+	s.SClear(key)
+	for _, member := range members {
+		s.add(key, string(member))
+	}
+}
+
+// SDiff 返回第一个集合与其余集合的差集，只有一个集合时返回其全部元素
 func (s *Set) SDiff(keys ...string) (val [][]byte) {
-	if len(keys) < 2 || !s.exist(keys[0]) {
+	if len(keys) == 0 || !s.exist(keys[0]) {
 		return
 	}
 	for v := range s.record[keys[0]] {
@@ -172,6 +223,74 @@ func (s *Set) SDiff(keys ...string) (val [][]byte) {
 	return
 }
 
+// KeyCount 返回所有非空集合的key的个数
+func (s *Set) KeyCount() int {
+	return s.keys.Len()
+}
+
+// Keys 返回所有非空集合的key
+func (s *Set) Keys() (keys []string) {
+	for k, v := range s.record {
+		if len(v) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// Rename 将集合 key 转移到 newKey 下，newKey 原有的数据会被覆盖，key 不存在时不做任何操作
+func (s *Set) Rename(key, newKey string) {
+	if v, ok := s.record[key]; ok {
+		members := s.members[key]
+		s.SClear(key)
+		s.SClear(newKey)
+		s.record[newKey] = v
+		s.members[newKey] = members
+		s.keys.Add(newKey)
+	}
+}
+
+// ScanKeys 按字典序依次将非空集合的key交给 fn，直到 fn 返回 false，用于基于游标的迭代
+// bounded 为 false 时从第一个key开始，否则从第一个大于 after 的key开始
+func (s *Set) ScanKeys(after string, bounded bool, fn func(key string) bool) {
+	s.keys.Ascend(after, bounded, fn)
+}
+
+// SScan 按字典序依次将集合 key 中的元素交给 fn，直到 fn 返回 false，after 和 bounded 的含义与 ScanKeys 相同
+func (s *Set) SScan(key, after string, bounded bool, fn func(member string) bool) {
+	if members, ok := s.members[key]; ok {
+		members.Ascend(after, bounded, fn)
+	}
+}
+
+// 将 member 加入集合 key，集合不存在时新建
+func (s *Set) add(key, member string) {
+	m, ok := s.record[key]
+	if !ok {
+		m = make(map[string]bool)
+		s.record[key] = m
+		s.members[key] = utils.NewOrderedKeys()
+		s.keys.Add(key)
+	}
+	if !m[member] {
+		m[member] = true
+		s.members[key].Add(member)
+	}
+}
+
+// 将 member 从集合 key 中删除，删空的集合不再保留，返回 member 之前是否存在
+func (s *Set) remove(key, member string) bool {
+	if !s.record[key][member] {
+		return false
+	}
+	delete(s.record[key], member)
+	s.members[key].Remove(member)
+	if len(s.record[key]) == 0 {
+		s.SClear(key)
+	}
+	return true
+}
+
 func (s *Set) exist(key string) bool {
 	_, exist := s.record[key]
 	return exist
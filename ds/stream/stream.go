@@ -0,0 +1,367 @@
+package stream
+
+import (
+	"KV_Storage/utils"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidID    = errors.New("stream: invalid stream ID")
+	ErrIDTooSmall   = errors.New("stream: the ID specified is equal or smaller than the target stream top item")
+	ErrGroupExists  = errors.New("stream: consumer group name already exists")
+	ErrNoGroup      = errors.New("stream: no such key or consumer group")
+	ErrInvalidValue = errors.New("stream: invalid encoded value")
+)
+
+type (
+	// Stream 流的索引，每个key对应一个只追加的消息序列以及若干消费者组
+	Stream struct {
+		record Record
+		keys   *utils.OrderedKeys // 所有流的key，用于基于游标的迭代
+	}
+	Record map[string]*streamRecord
+
+	streamRecord struct {
+		entries []Entry // 按ID递增排列
+		lastID  ID      // 曾经加入过的最大ID
+		groups  map[string]*group
+	}
+
+	group struct {
+		lastID  ID                   // 已投递给该组的最大ID
+		pending map[ID]*PendingEntry // 已投递但尚未确认的消息
+	}
+
+	// ID 消息ID，由毫秒时间戳和序号组成
+	ID struct {
+		Ms  uint64
+		Seq uint64
+	}
+
+	// Entry 流中的一条消息，Fields 中域和值交替排列
+	Entry struct {
+		ID     ID
+		Fields [][]byte
+	}
+
+	// PendingEntry 消费者组中已投递但尚未确认的消息
+	PendingEntry struct {
+		ID            ID
+		Consumer      string
+		DeliveryTime  uint64 // 最后一次投递的毫秒时间戳
+		DeliveryCount uint64 // 投递的次数
+	}
+)
+
+// MaxID 最大的消息ID
+var MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// New new a stream idx
+func New() *Stream {
+	return &Stream{record: make(Record), keys: utils.NewOrderedKeys()}
+}
+
+// ParseID 解析形如 ms-seq 的消息ID，省略 seq 时其值为 defaultSeq
+func ParseID(s string, defaultSeq uint64) (id ID, err error) {
+	msPart, seqPart, hasSeq := s, "", false
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		msPart, seqPart, hasSeq = s[:i], s[i+1:], true
+	}
+
+	if id.Ms, err = strconv.ParseUint(msPart, 10, 64); err != nil {
+		return ID{}, ErrInvalidID
+	}
+	id.Seq = defaultSeq
+	if hasSeq {
+		if id.Seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return ID{}, ErrInvalidID
+		}
+	}
+	return id, nil
+}
+
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare 比较两个ID的大小，返回 -1、0 或 1
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+// Next 返回比当前ID大的最小ID，已经是最大ID时 ok 为 false
+func (id ID) Next() (next ID, ok bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return ID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// NextID 根据 spec 生成要加入流 key 的消息ID，生成的ID一定大于流中已有的ID
+// spec 为 * 时根据当前时间 now 自动生成，为 ms-* 时自动生成序号，否则为完整的ID
+func (s *Stream) NextID(key, spec string, now uint64) (ID, error) {
+	var last ID
+	if r, ok := s.record[key]; ok {
+		last = r.lastID
+	}
+
+	switch {
+	case spec == "*":
+		if now > last.Ms {
+			return ID{Ms: now}, nil
+		}
+		if next, ok := last.Next(); ok {
+			return next, nil
+		}
+		return ID{}, ErrIDTooSmall
+	case strings.HasSuffix(spec, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(spec, "-*"), 10, 64)
+		if err != nil {
+			return ID{}, ErrInvalidID
+		}
+		if ms > last.Ms {
+			return ID{Ms: ms}, nil
+		}
+		if ms == last.Ms && last.Seq < math.MaxUint64 {
+			return ID{Ms: ms, Seq: last.Seq + 1}, nil
+		}
+		return ID{}, ErrIDTooSmall
+	}
+
+	id, err := ParseID(spec, 0)
+	if err != nil {
+		return ID{}, err
+	}
+	if id.Compare(ID{}) == 0 || id.Compare(last) <= 0 {
+		return ID{}, ErrIDTooSmall
+	}
+	return id, nil
+}
+
+// XAdd 将消息加入流 key 的末尾，id 必须大于流中已有的ID
+func (s *Stream) XAdd(key string, id ID, fields [][]byte) {
+	r := s.getOrCreate(key)
+	r.entries = append(r.entries, Entry{ID: id, Fields: fields})
+	if id.Compare(r.lastID) > 0 {
+		r.lastID = id
+	}
+}
+
+// XLen 返回流 key 中消息的个数
+func (s *Stream) XLen(key string) int {
+	if !s.exist(key) {
+		return 0
+	}
+	return len(s.record[key].entries)
+}
+
+// LastID 返回流 key 中曾经加入过的最大ID
+func (s *Stream) LastID(key string) ID {
+	if !s.exist(key) {
+		return ID{}
+	}
+	return s.record[key].lastID
+}
+
+// XRange 返回流 key 中ID介于 start 和 end 之间（包含）的消息，count 大于0时最多返回 count 条
+// reverse 为 true 时按ID从大到小的顺序返回
+func (s *Stream) XRange(key string, start, end ID, count int, reverse bool) (val []Entry) {
+	if !s.exist(key) || start.Compare(end) > 0 {
+		return
+	}
+
+	entries := s.record[key].entries
+	lo := sort.Search(len(entries), func(i int) bool { return entries[i].ID.Compare(start) >= 0 })
+	hi := sort.Search(len(entries), func(i int) bool { return entries[i].ID.Compare(end) > 0 })
+	for i := lo; i < hi; i++ {
+		e := entries[i]
+		if reverse {
+			e = entries[lo+hi-1-i]
+		}
+		val = append(val, e)
+		if count > 0 && len(val) == count {
+			break
+		}
+	}
+	return
+}
+
+// XClear 删除流 key 及其所有的消费者组
+func (s *Stream) XClear(key string) {
+	delete(s.record, key)
+	s.keys.Remove(key)
+}
+
+// XGroupCreate 为流 key 创建消费者组，只投递ID大于 id 的消息，流不存在时会创建一个空的流
+func (s *Stream) XGroupCreate(key, name string, id ID) error {
+	r := s.getOrCreate(key)
+	if _, ok := r.groups[name]; ok {
+		return ErrGroupExists
+	}
+	r.groups[name] = &group{lastID: id, pending: make(map[ID]*PendingEntry)}
+	return nil
+}
+
+// GroupExists 判断流 key 中是否存在消费者组
+func (s *Stream) GroupExists(key, name string) bool {
+	return s.getGroup(key, name) != nil
+}
+
+// GroupLastID 返回消费者组已投递的最大ID
+func (s *Stream) GroupLastID(key, name string) ID {
+	if g := s.getGroup(key, name); g != nil {
+		return g.lastID
+	}
+	return ID{}
+}
+
+// Groups 返回流 key 中的所有消费者组的名称及已投递的最大ID
+func (s *Stream) Groups(key string) map[string]ID {
+	res := make(map[string]ID)
+	if s.exist(key) {
+		for name, g := range s.record[key].groups {
+			res[name] = g.lastID
+		}
+	}
+	return res
+}
+
+// SetPending 更新消费者组的投递状态：已投递的最大ID至少为 lastID，并用 entries 覆盖对应的未确认消息
+// 组不存在时返回 ErrNoGroup
+func (s *Stream) SetPending(key, name string, lastID ID, entries []PendingEntry) error {
+	g := s.getGroup(key, name)
+	if g == nil {
+		return ErrNoGroup
+	}
+
+	if lastID.Compare(g.lastID) > 0 {
+		g.lastID = lastID
+	}
+	for i := range entries {
+		pe := entries[i]
+		g.pending[pe.ID] = &pe
+	}
+	return nil
+}
+
+// XAck 确认消费者组中的消息，将其从未确认消息中删除，返回被确认的消息个数
+func (s *Stream) XAck(key, name string, ids ...ID) (count int) {
+	g := s.getGroup(key, name)
+	if g == nil {
+		return
+	}
+
+	for _, id := range ids {
+		if _, ok := g.pending[id]; ok {
+			delete(g.pending, id)
+			count++
+		}
+	}
+	return
+}
+
+// Pending 返回消费者组中ID介于 start 和 end 之间的未确认消息，按ID递增排列
+// consumer 不为空时只返回该消费者的消息，count 大于0时最多返回 count 条
+func (s *Stream) Pending(key, name string, start, end ID, count int, consumer string) (val []PendingEntry) {
+	g := s.getGroup(key, name)
+	if g == nil {
+		return
+	}
+
+	for id, pe := range g.pending {
+		if id.Compare(start) >= 0 && id.Compare(end) <= 0 && (consumer == "" || pe.Consumer == consumer) {
+			val = append(val, *pe)
+		}
+	}
+	sort.Slice(val, func(i, j int) bool { return val[i].ID.Compare(val[j].ID) < 0 })
+	if count > 0 && len(val) > count {
+		val = val[:count]
+	}
+	return
+}
+
+// Get 返回流 key 中ID为 id 的消息
+func (s *Stream) Get(key string, id ID) (Entry, bool) {
+	if !s.exist(key) {
+		return Entry{}, false
+	}
+
+	entries := s.record[key].entries
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].ID.Compare(id) >= 0 })
+	if i < len(entries) && entries[i].ID == id {
+		return entries[i], true
+	}
+	return Entry{}, false
+}
+
+// Exists 判断流 key 是否存在，没有消息但创建了消费者组的流也是存在的
+func (s *Stream) Exists(key string) bool {
+	return s.exist(key)
+}
+
+// KeyCount 返回所有流的key的个数
+func (s *Stream) KeyCount() int {
+	return s.keys.Len()
+}
+
+// Keys 返回所有流的key
+func (s *Stream) Keys() (keys []string) {
+	for k := range s.record {
+		keys = append(keys, k)
+	}
+	return
+}
+
+// Rename 将流 key 转移到 newKey 下，newKey 原有的数据会被覆盖，key 不存在时不做任何操作
+func (s *Stream) Rename(key, newKey string) {
+	if v, ok := s.record[key]; ok {
+		s.XClear(key)
+		s.record[newKey] = v
+		s.keys.Add(newKey)
+	}
+}
+
+// ScanKeys 按字典序依次将流的key交给 fn，直到 fn 返回 false，用于基于游标的迭代
+// bounded 为 false 时从第一个key开始，否则从第一个大于 after 的key开始
+func (s *Stream) ScanKeys(after string, bounded bool, fn func(key string) bool) {
+	s.keys.Ascend(after, bounded, fn)
+}
+
+func (s *Stream) exist(key string) bool {
+	_, exist := s.record[key]
+	return exist
+}
+
+func (s *Stream) getOrCreate(key string) *streamRecord {
+	r, ok := s.record[key]
+	if !ok {
+		r = &streamRecord{groups: make(map[string]*group)}
+		s.record[key] = r
+		s.keys.Add(key)
+	}
+	return r
+}
+
+func (s *Stream) getGroup(key, name string) *group {
+	if !s.exist(key) {
+		return nil
+	}
+	return s.record[key].groups[name]
+}
@@ -1,6 +1,7 @@
 package zset
 
 import (
+	"KV_Storage/utils"
 	"math"
 	"math/rand"
 )
@@ -13,11 +14,13 @@ const (
 type (
 	SortedSet struct {
 		record map[string]*SortedSetNode
+		keys   *utils.OrderedKeys // 所有非空有序集合的key，用于基于游标的迭代
 	}
 
 	SortedSetNode struct {
-		dict map[string]*sklNode
-		skl  *skipList
+		dict    map[string]*sklNode
+		skl     *skipList
+		members *utils.OrderedKeys // 按字典序排列的成员，用于基于游标的迭代
 	}
 	sklLevel struct {
 		forward *sklNode
@@ -39,7 +42,8 @@ type (
 
 func New() *SortedSet {
 	return &SortedSet{
-		make(map[string]*SortedSetNode),
+		record: make(map[string]*SortedSetNode),
+		keys:   utils.NewOrderedKeys(),
 	}
 }
 
@@ -48,10 +52,12 @@ func (z *SortedSet) ZAdd(key string, score float64, member string) {
 	if !z.exist(key) { // 每个key对应一个ZSet，如果不存在则创建
 
 		node := &SortedSetNode{ // 每个ZSet包含一个跳表和一个值为跳表节点的map字典
-			dict: make(map[string]*sklNode),
-			skl:  newSkipList(),
+			dict:    make(map[string]*sklNode),
+			skl:     newSkipList(),
+			members: utils.NewOrderedKeys(),
 		}
 		z.record[key] = node
+		z.keys.Add(key)
 	}
 
 	item := z.record[key]         // 拿到key对应ZSet
@@ -65,6 +71,7 @@ func (z *SortedSet) ZAdd(key string, score float64, member string) {
 		}
 	} else {
 		node = item.skl.sklInsert(score, member)
+		item.members.Add(member)
 	}
 
 	if node != nil {
@@ -174,12 +181,22 @@ func (z *SortedSet) ZRem(key, member string) bool {
 	if exist {
 		z.record[key].skl.sklDelete(v.score, member)
 		delete(z.record[key].dict, member)
+		z.record[key].members.Remove(member)
+		if len(z.record[key].dict) == 0 { // 删空的有序集合不再保留
+			z.ZClear(key)
+		}
 		return true
 	}
 
 	return false
 }
 
+// ZClear 删除整个有序集合
+func (z *SortedSet) ZClear(key string) {
+	delete(z.record, key)
+	z.keys.Remove(key)
+}
+
 // ZGetByRank 根据排名获取member及分值信息，从小到大排列遍历，即分值最低排名为0，依次类推
 func (z *SortedSet) ZGetByRank(key string, rank int) (val []interface{}) {
 	if !z.exist(key) {
@@ -277,6 +294,44 @@ func (z *SortedSet) ZRevScoreRange(key string, max, min float64) (val []interfac
 	return
 }
 
+// KeyCount 返回所有非空有序集合的key的个数
+func (z *SortedSet) KeyCount() int {
+	return z.keys.Len()
+}
+
+// Keys 返回所有非空有序集合的key
+func (z *SortedSet) Keys() (keys []string) {
+	for k, v := range z.record {
+		if len(v.dict) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	return
+}
+
+// Rename 将有序集合 key 转移到 newKey 下，newKey 原有的数据会被覆盖，key 不存在时不做任何操作
+func (z *SortedSet) Rename(key, newKey string) {
+	if v, ok := z.record[key]; ok {
+		z.ZClear(key)
+		z.record[newKey] = v
+		z.keys.Add(newKey)
+	}
+}
+
+// ScanKeys 按字典序依次将非空有序集合的key交给 fn，直到 fn 返回 false，用于基于游标的迭代
+// bounded 为 false 时从第一个key开始，否则从第一个大于 after 的key开始
+func (z *SortedSet) ScanKeys(after string, bounded bool, fn func(key string) bool) {
+	z.keys.Ascend(after, bounded, fn)
+}
+
+// ZScan 按字典序依次将有序集合 key 中的成员交给 fn，直到 fn 返回 false，after 和 bounded 的含义与 ScanKeys 相同
+func (z *SortedSet) ZScan(key, after string, bounded bool, fn func(member string) bool) {
+	if !z.exist(key) {
+		return
+	}
+	z.record[key].members.Ascend(after, bounded, fn)
+}
+
 func (z *SortedSet) exist(key string) bool {
 	_, exist := z.record[key]
 	return exist
@@ -0,0 +1,342 @@
+package KV_Storage
+
+import (
+	"KV_Storage/storage"
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// 主动过期相关的参数，参考 Redis 的 active expire cycle
+const (
+	// 每轮采样的带过期时间的 key 的个数
+	activeExpireLookups = 20
+
+	// 一轮采样中已过期的 key 的比例超过该值时，认为过期的 key 还比较多，继续下一轮采样
+	activeExpireStalePercent = 25
+
+	// 每个周期中主动过期最多占用的时间比例
+	activeExpireTimePercent = 25
+)
+
+// 启动后台的主动过期任务，定期从过期字典中采样并删除已过期的 key
+// 惰性删除只会在 key 被访问时触发，不再被访问的过期 key 需要依靠主动过期来清理
+func (db *KvDB) startActiveExpire() {
+	hz := db.config.ActiveExpireHz
+	if hz <= 0 {
+		return
+	}
+
+	interval := time.Second / time.Duration(hz)
+	timeLimit := interval * activeExpireTimePercent / 100
+
+	db.expireDone = make(chan struct{})
+	db.expireWg.Add(1)
+	go func() {
+		defer db.expireWg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-db.expireDone:
+				return
+			case <-ticker.C:
+				db.activeExpireCycle(timeLimit)
+			}
+		}
+	}()
+}
+
+// 停止主动过期任务，并等待正在执行的周期结束
+func (db *KvDB) stopActiveExpire() {
+	if db.expireDone == nil {
+		return
+	}
+	close(db.expireDone)
+	db.expireWg.Wait()
+	db.expireDone = nil
+}
+
+// 执行一个主动过期周期
+// 如果采样中过期 key 的比例较高，说明还有很多过期 key 未被删除，则继续采样，直到比例降低或超出时间限制
+func (db *KvDB) activeExpireCycle(timeLimit time.Duration) {
+	start := time.Now()
+	for {
+		sampled, expired := db.activeExpireSample()
+		if sampled == 0 || expired*100 <= sampled*activeExpireStalePercent {
+			return
+		}
+		if time.Since(start) > timeLimit {
+			return
+		}
+	}
+}
+
+// 依次对每种数据类型的过期字典进行采样，返回采样和过期的 key 的总数
+func (db *KvDB) activeExpireSample() (sampled, expired int) {
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		s, e := db.activeExpireSampleType(dataType)
+		sampled += s
+		expired += e
+	}
+	return
+}
+
+// 从指定类型的过期字典中采样至多 activeExpireLookups 个 key，删除其中已过期的 key
+// map 的遍历顺序是随机的，从头开始遍历即可得到随机的样本
+func (db *KvDB) activeExpireSampleType(dataType DataType) (sampled, expired int) {
+	mu := db.idxLock(dataType)
+	mu.Lock()
+	defer mu.Unlock()
+
+	for key := range db.expires[dataType] {
+		if sampled >= activeExpireLookups {
+			break
+		}
+		sampled++
+		if db.expireIfNeeded([]byte(key), dataType) {
+			expired++
+		}
+	}
+	return
+}
+
+// Expire 设置key的过期时间（秒），对所有存在该key的数据类型均生效
+func (db *KvDB) Expire(key []byte, seconds uint32) error {
+	if seconds <= 0 {
+		return ErrInvalidTTL
+	}
+	return db.PExpireAt(key, int64(nowMillis())+int64(seconds)*1000)
+}
+
+// PExpire 设置key的过期时间（毫秒）
+func (db *KvDB) PExpire(key []byte, milliseconds uint64) error {
+	if milliseconds <= 0 {
+		return ErrInvalidTTL
+	}
+	return db.PExpireAt(key, int64(nowMillis()+milliseconds))
+}
+
+// ExpireAt 设置key在指定的时间点（秒级 Unix 时间戳）过期，时间点已过去时key会被立即删除
+func (db *KvDB) ExpireAt(key []byte, timestamp int64) error {
+	return db.PExpireAt(key, timestamp*1000)
+}
+
+// PExpireAt 设置key在指定的时间点（毫秒级 Unix 时间戳）过期，时间点已过去时key会被立即删除
+func (db *KvDB) PExpireAt(key []byte, timestamp int64) (err error) {
+	if err = db.checkKeyValue(key, nil); err != nil {
+		return
+	}
+	if timestamp <= 0 {
+		return ErrInvalidTTL
+	}
+
+	exist := false
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		mu := db.idxLock(dataType)
+		mu.Lock()
+		if !db.expireIfNeeded(key, dataType) && db.keyExists(key, dataType) {
+			exist = true
+			if err = db.setExpire(key, dataType, uint64(timestamp)); err == nil {
+				db.expireIfNeeded(key, dataType)
+			}
+		}
+		mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+
+	if !exist {
+		return ErrKeyNotExist
+	}
+	return
+}
+
+// Persist 清除key的过期时间
+func (db *KvDB) Persist(key []byte) error {
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		if err := db.persist(key, dataType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TTL 获取key的剩余生存时间（秒），key不存在或者没有设置过期时间时返回0
+func (db *KvDB) TTL(key []byte) uint32 {
+	return uint32((db.PTTL(key) + 500) / 1000)
+}
+
+// PTTL 获取key的剩余生存时间（毫秒），key不存在或者没有设置过期时间时返回0
+func (db *KvDB) PTTL(key []byte) (ttl uint64) {
+	now := nowMillis()
+	for dataType := String; dataType < dataTypeNum; dataType++ {
+		mu := db.idxLock(dataType)
+		mu.RLock()
+		deadline, exist := db.expires[dataType][string(key)]
+		alive := exist && deadline > now && db.keyExists(key, dataType)
+		mu.RUnlock()
+
+		if alive {
+			return deadline - now
+		}
+	}
+	return
+}
+
+// 清除指定类型中key的过期时间
+func (db *KvDB) persist(key []byte, dataType DataType) error {
+	mu := db.idxLock(dataType)
+	mu.Lock()
+	defer mu.Unlock()
+
+	return db.removeExpire(key, dataType)
+}
+
+// 设置key的过期时间，并将其作为一条entry写入数据文件，调用方需持有相应类型的写锁
+func (db *KvDB) setExpire(key []byte, dataType DataType, deadline uint64) error {
+	expireMark, _ := expireMarks(dataType)
+	e := storage.NewEntry(key, nil, []byte(strconv.FormatUint(deadline, 10)), dataType, expireMark)
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	db.expires[dataType][string(key)] = deadline
+	return nil
+}
+
+// 清除key的过期时间，并将其作为一条entry写入数据文件，调用方需持有相应类型的写锁
+func (db *KvDB) removeExpire(key []byte, dataType DataType) error {
+	if _, exist := db.expires[dataType][string(key)]; !exist {
+		return nil
+	}
+
+	_, persistMark := expireMarks(dataType)
+	e := storage.NewEntryNoExtra(key, nil, dataType, persistMark)
+	if err := db.store(e); err != nil {
+		return err
+	}
+
+	delete(db.expires[dataType], string(key))
+	return nil
+}
+
+// 各数据类型中设置和清除过期时间的操作标识
+func expireMarks(dataType DataType) (expire, persist uint16) {
+	switch dataType {
+	case String:
+		return StringExpire, StringPersist
+	case List:
+		return ListExpire, ListPersist
+	case Hash:
+		return HashExpire, HashPersist
+	case Set:
+		return SetExpire, SetPersist
+	case ZSet:
+		return ZSetExpire, ZSetPersist
+	case Stream:
+		return StreamExpire, StreamPersist
+	case JSON:
+		return JSONExpire, JSONPersist
+	}
+	return math.MaxUint16, math.MaxUint16
+}
+
+// 判断key是否已过期，只读取过期字典，调用方需持有相应类型的锁
+func (db *KvDB) isExpired(key []byte, dataType DataType) bool {
+	deadline, exist := db.expires[dataType][string(key)]
+	return exist && nowMillis() > deadline
+}
+
+// 检查key是否过期，如果过期则删除相应的数据，调用方需持有相应类型的写锁
+func (db *KvDB) expireIfNeeded(key []byte, dataType DataType) (expired bool) {
+	if !db.isExpired(key, dataType) {
+		return
+	}
+
+	expired = true
+	//删除过期字典对应的key，删除数据时写入的entry在恢复时会一并清除过期时间
+	delete(db.expires[dataType], string(key))
+
+	//删除索引及数据
+	if err := db.removeKey(key, dataType); err != nil {
+		log.Printf("remove expired key err [%+v] [%+v]\n", key, err)
+	}
+	return
+}
+
+// 集合类型的数据被删空之后，其过期时间也随之失效，调用方需持有相应类型的写锁
+func (db *KvDB) clearExpireIfEmpty(key []byte, dataType DataType) {
+	if !db.keyExists(key, dataType) {
+		if err := db.removeExpire(key, dataType); err != nil {
+			log.Printf("remove expire of empty key err [%+v] [%+v]\n", key, err)
+		}
+	}
+}
+
+// 判断指定类型中是否存在key（不检查是否过期），调用方需持有相应类型的锁
+func (db *KvDB) keyExists(key []byte, dataType DataType) bool {
+	k := string(key)
+	switch dataType {
+	case String:
+		return db.strIndex.idxList.Exist(key)
+	case List:
+		return db.listIndex.indexes.LLen(k) > 0
+	case Hash:
+		return db.hashIndex.indexes.HLen(k) > 0
+	case Set:
+		return db.setIndex.indexes.SCard(k) > 0
+	case ZSet:
+		return db.zsetIndex.indexes.ZCard(k) > 0
+	case Stream:
+		return db.streamIndex.indexes.Exists(k)
+	case JSON:
+		return db.jsonIndex.indexes.Exists(k)
+	}
+	return false
+}
+
+// 删除指定类型中的key及其全部数据，并写入相应的删除entry，调用方需持有相应类型的写锁
+func (db *KvDB) removeKey(key []byte, dataType DataType) error {
+	if !db.keyExists(key, dataType) {
+		return nil
+	}
+
+	k := string(key)
+	var e *storage.Entry
+	switch dataType {
+	case String:
+		db.strIndex.idxList.Remove(key)
+		e = storage.NewEntryNoExtra(key, nil, String, StringRem)
+	case List:
+		db.listIndex.indexes.LClear(k)
+		e = storage.NewEntryNoExtra(key, nil, List, ListLClear)
+	case Hash:
+		db.hashIndex.indexes.HClear(k)
+		e = storage.NewEntryNoExtra(key, nil, Hash, HashHClear)
+	case Set:
+		db.setIndex.indexes.SClear(k)
+		e = storage.NewEntryNoExtra(key, nil, Set, SetSClear)
+	case ZSet:
+		db.zsetIndex.indexes.ZClear(k)
+		e = storage.NewEntryNoExtra(key, nil, ZSet, ZSetZClear)
+	case Stream:
+		db.streamIndex.indexes.XClear(k)
+		e = storage.NewEntryNoExtra(key, nil, Stream, StreamXClear)
+	case JSON:
+		db.jsonIndex.indexes.Clear(k)
+		e = storage.NewEntryNoExtra(key, nil, JSON, JSONClear)
+	default:
+		return nil
+	}
+	return db.store(e)
+}
+
+// 当前的毫秒级 Unix 时间戳
+func nowMillis() uint64 {
+	return uint64(time.Now().UnixNano() / int64(time.Millisecond))
+}
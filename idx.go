@@ -1,7 +1,9 @@
 package KV_Storage
 
 import (
+	"KV_Storage/ds/jsondoc"
 	"KV_Storage/ds/list"
+	"KV_Storage/ds/stream"
 	"KV_Storage/index"
 	"KV_Storage/storage"
 	"KV_Storage/utils"
@@ -11,7 +13,6 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 )
 
 // DataType 数据类型定义
@@ -24,12 +25,20 @@ const (
 	Hash
 	Set
 	ZSet
+	Stream
+	JSON
+
+	// 数据类型的个数
+	dataTypeNum
 )
 
 // 字符串相关操作标识
 const (
 	StringSet uint16 = iota
 	StringRem
+	StringExpire
+	StringPersist
+	StringRename
 )
 
 // 列表相关操作标识
@@ -42,12 +51,21 @@ const (
 	ListLInsert
 	ListLSet
 	ListLTrim
+	ListLClear
+	ListExpire
+	ListPersist
+	ListLMove
+	ListRename
 )
 
 // 哈希相关操作标识
 const (
 	HashHSet uint16 = iota
 	HashHDel
+	HashHClear
+	HashExpire
+	HashPersist
+	HashRename
 )
 
 // 集合相关操作标识
@@ -55,12 +73,43 @@ const (
 	SetSAdd uint16 = iota
 	SetSRem
 	SetSMove
+	SetSClear
+	SetExpire
+	SetPersist
+	SetSStore
+	SetRename
 )
 
 // 有序集合相关操作标识
 const (
 	ZSetZAdd uint16 = iota
 	ZSetZRem
+	ZSetZClear
+	ZSetExpire
+	ZSetPersist
+	ZSetRename
+)
+
+// 流相关操作标识
+const (
+	StreamXAdd uint16 = iota
+	StreamXGroupCreate
+	StreamXClaim
+	StreamXAck
+	StreamXClear
+	StreamExpire
+	StreamPersist
+	StreamRename
+)
+
+// JSON文档相关操作标识
+const (
+	JSONSet uint16 = iota
+	JSONDel
+	JSONClear
+	JSONExpire
+	JSONPersist
+	JSONRename
 )
 
 // 建立字符串索引
@@ -69,16 +118,17 @@ func (db *KvDB) buildStringIndex(idx *index.Indexer, opt uint16) {
 		return
 	}
 
-	now := uint32(time.Now().Unix())
-	if deadline, exist := db.expires[string(idx.Meta.Key)]; exist && deadline <= now {
-		return
-	}
-
 	switch opt {
 	case StringSet:
 		db.strIndex.idxList.Put(idx.Meta.Key, idx)
 	case StringRem:
 		db.strIndex.idxList.Remove(idx.Meta.Key)
+		delete(db.expires[String], string(idx.Meta.Key))
+	case StringRename:
+		// 改名的entry中保存的是newKey及其value，旧的key保存在extra中
+		db.strIndex.idxList.Put(idx.Meta.Key, idx)
+		db.strIndex.idxList.Remove(idx.Meta.Extra)
+		db.renameExpire(string(idx.Meta.Extra), string(idx.Meta.Key), String)
 	}
 }
 
@@ -112,7 +162,8 @@ func (db *KvDB) buildListIndex(idx *index.Indexer, opt uint16) {
 			}
 		}
 	case ListLSet:
-		if i, err := strconv.Atoi(string(idx.Meta.Extra)); err == nil {
+		// 旧版本不检查下标就写入日志，且负数下标当时不会修改列表，这里保持相同的结果
+		if i, err := strconv.Atoi(string(idx.Meta.Extra)); err == nil && i >= 0 {
 			db.listIndex.indexes.LSet(key, i, idx.Meta.Value)
 		}
 	case ListLTrim:
@@ -124,6 +175,21 @@ func (db *KvDB) buildListIndex(idx *index.Indexer, opt uint16) {
 
 			db.listIndex.indexes.LTrim(string(idx.Meta.Key), start, end)
 		}
+	case ListLClear:
+		db.listIndex.indexes.LClear(key)
+		delete(db.expires[List], key)
+	case ListLMove:
+		s := strings.Split(string(idx.Meta.Extra), ExtraSeparator)
+		if len(s) == 2 {
+			from, err1 := strconv.Atoi(s[0])
+			to, err2 := strconv.Atoi(s[1])
+			if err1 == nil && err2 == nil {
+				db.listIndex.move(key, string(idx.Meta.Value), ListDirection(from), ListDirection(to))
+			}
+		}
+	case ListRename:
+		db.listIndex.indexes.Rename(key, string(idx.Meta.Value))
+		db.renameExpire(key, string(idx.Meta.Value), List)
 	}
 }
 
@@ -140,6 +206,12 @@ func (db *KvDB) buildHashIndex(idx *index.Indexer, opt uint16) {
 		db.hashIndex.indexes.HSet(key, string(idx.Meta.Extra), idx.Meta.Value)
 	case HashHDel:
 		db.hashIndex.indexes.HDel(key, string(idx.Meta.Extra))
+	case HashHClear:
+		db.hashIndex.indexes.HClear(key)
+		delete(db.expires[Hash], key)
+	case HashRename:
+		db.hashIndex.indexes.Rename(key, string(idx.Meta.Value))
+		db.renameExpire(key, string(idx.Meta.Value), Hash)
 	}
 }
 
@@ -159,6 +231,17 @@ func (db *KvDB) buildSetIndex(idx *index.Indexer, opt uint16) {
 	case SetSMove:
 		extra := idx.Meta.Extra
 		db.setIndex.indexes.SMove(key, string(extra), idx.Meta.Value)
+	case SetSClear:
+		db.setIndex.indexes.SClear(key)
+		delete(db.expires[Set], key)
+	case SetSStore:
+		if members, err := utils.DecodeValues(idx.Meta.Value); err == nil {
+			db.setIndex.indexes.SStore(key, members)
+			delete(db.expires[Set], key)
+		}
+	case SetRename:
+		db.setIndex.indexes.Rename(key, string(idx.Meta.Value))
+		db.renameExpire(key, string(idx.Meta.Value), Set)
 	}
 }
 
@@ -177,18 +260,107 @@ func (db *KvDB) buildZsetIndex(idx *index.Indexer, opt uint16) {
 		}
 	case ZSetZRem:
 		db.zsetIndex.indexes.ZRem(key, string(idx.Meta.Value))
+	case ZSetZClear:
+		db.zsetIndex.indexes.ZClear(key)
+		delete(db.expires[ZSet], key)
+	case ZSetRename:
+		db.zsetIndex.indexes.Rename(key, string(idx.Meta.Value))
+		db.renameExpire(key, string(idx.Meta.Value), ZSet)
+	}
+}
+
+// 建立流索引
+func (db *KvDB) buildStreamIndex(idx *index.Indexer, opt uint16) {
+
+	if db.streamIndex == nil || idx == nil {
+		return
+	}
+
+	key := string(idx.Meta.Key)
+	switch opt {
+	case StreamXAdd:
+		id, err := stream.ParseID(string(idx.Meta.Extra), 0)
+		if err != nil {
+			return
+		}
+		if fields, err := utils.DecodeValues(idx.Meta.Value); err == nil {
+			db.streamIndex.indexes.XAdd(key, id, fields)
+		}
+	case StreamXGroupCreate:
+		if id, err := stream.ParseID(string(idx.Meta.Extra), 0); err == nil {
+			db.streamIndex.indexes.XGroupCreate(key, string(idx.Meta.Value), id)
+		}
+	case StreamXClaim:
+		if group, lastID, entries, err := decodeStreamClaim(idx.Meta.Value); err == nil {
+			db.streamIndex.indexes.SetPending(key, group, lastID, entries)
+		}
+	case StreamXAck:
+		if group, ids, err := decodeStreamAck(idx.Meta.Value); err == nil {
+			db.streamIndex.indexes.XAck(key, group, ids...)
+		}
+	case StreamXClear:
+		db.streamIndex.indexes.XClear(key)
+		delete(db.expires[Stream], key)
+	case StreamRename:
+		db.streamIndex.indexes.Rename(key, string(idx.Meta.Value))
+		db.renameExpire(key, string(idx.Meta.Value), Stream)
 	}
 }
 
-// 从文件中加载String、List、Hash、Set、ZSet索引
+// 建立JSON文档索引
+func (db *KvDB) buildJSONIndex(idx *index.Indexer, opt uint16) {
+
+	if db.jsonIndex == nil || idx == nil {
+		return
+	}
+
+	key := string(idx.Meta.Key)
+	switch opt {
+	case JSONSet:
+		path, err := jsondoc.ParsePath(string(idx.Meta.Extra))
+		if err != nil {
+			return
+		}
+		if value, err := jsondoc.Unmarshal(idx.Meta.Value); err == nil {
+			db.jsonIndex.indexes.Set(key, path, value)
+		}
+	case JSONDel:
+		if path, err := jsondoc.ParsePath(string(idx.Meta.Extra)); err == nil {
+			db.jsonIndex.indexes.Del(key, path)
+		}
+	case JSONClear:
+		db.jsonIndex.indexes.Clear(key)
+		delete(db.expires[JSON], key)
+	case JSONRename:
+		db.jsonIndex.indexes.Rename(key, string(idx.Meta.Value))
+		db.renameExpire(key, string(idx.Meta.Value), JSON)
+	}
+}
+
+// 根据日志中设置和清除过期时间的entry重建过期字典
+func (db *KvDB) buildExpireIndex(idx *index.Indexer, dataType DataType, opt uint16) {
+	key := string(idx.Meta.Key)
+	if expireMark, _ := expireMarks(dataType); opt == expireMark {
+		if deadline, err := strconv.ParseUint(string(idx.Meta.Extra), 10, 64); err == nil {
+			db.expires[dataType][key] = deadline
+		}
+		return
+	}
+	delete(db.expires[dataType], key)
+}
+
+// 从文件中加载String、List、Hash、Set、ZSet、Stream、JSON索引
 func (db *KvDB) loadIdxFromFiles() error {
 	if db.archFiles == nil && db.activeFile == nil {
 		return nil
 	}
 
+	// 扫描得到的每种类型活跃文件中有效数据的末尾位置
+	activeOffsets := make([]int64, dataTypeNum)
+
 	wg := sync.WaitGroup{}
-	wg.Add(5)
-	for dataType := 0; dataType < 5; dataType++ { // 遍历五种数据类型的文件
+	wg.Add(int(dataTypeNum))
+	for dataType := 0; dataType < int(dataTypeNum); dataType++ { // 遍历每种数据类型的文件
 		go func(dType uint16) { // 分别开启一个goroutine去执行
 			defer func() { // 每个goroutine最后要将wg减一
 				wg.Done()
@@ -211,33 +383,41 @@ func (db *KvDB) loadIdxFromFiles() error {
 			for i := 0; i < len(fileIds); i++ {
 				fid := uint32(fileIds[i])
 				df := dbFile[fid]
-				var offset int64 = 0
-
-				for offset <= db.config.BlockSize {
-					if e, err := df.Read(offset); err == nil {
-						idx := &index.Indexer{
-							Meta:      e.Meta,
-							FileId:    fid,
-							EntrySize: e.Size(),
-							Offset:    offset,
-						}
-						offset += int64(e.Size())
 
-						if len(e.Meta.Key) > 0 {
-							if err := db.buildIndex(e, idx); err != nil {
-								log.Fatalf("a fatal err occurred, the db can not open.[%+v]", err)
-							}
-						}
-					} else {
+				// 使用带缓冲的顺序读取扫描整个文件
+				scanner := df.NewScanner()
+				for {
+					e, offset, err := scanner.Next()
+					if err != nil {
 						if err == io.EOF {
 							break
 						}
 						log.Fatalf("a fatal err occurred, the db can not open.[%+v]", err)
 					}
+
+					idx := &index.Indexer{
+						Meta:      e.Meta,
+						FileId:    fid,
+						EntrySize: e.Size(),
+						Offset:    offset,
+					}
+					if err := db.buildIndex(e, idx); err != nil {
+						log.Fatalf("a fatal err occurred, the db can not open.[%+v]", err)
+					}
+				}
+
+				if fid == db.activeFileIds[dType] {
+					activeOffsets[dType] = scanner.Offset()
 				}
 			}
 		}(uint16(dataType))
 	}
 	wg.Wait()
+
+	// 异常退出时 db.meta 中记录的写偏移可能已经过时，以扫描到的实际位置为准，避免覆盖已写入的数据
+	for dataType, file := range db.activeFile {
+		file.Offset = activeOffsets[dataType]
+		db.meta.ActiveWriteOff[dataType] = file.Offset
+	}
 	return nil
 }
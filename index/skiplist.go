@@ -22,10 +22,12 @@ type (
 		Node
 		key   []byte
 		value interface{}
+		prev  *Element // 最底层的前一个节点，用于逆序遍历
 	}
 
 	SkipList struct {
 		Node
+		tail           *Element // 最后一个节点
 		maxLevel       int
 		Len            int
 		randsource     rand.Source
@@ -51,21 +53,34 @@ func (e *Element) Next() *Element {
 	return e.next[0]
 }
 
+// Prev 返回前一个节点，当前节点是第一个节点时返回 nil
+func (e *Element) Prev() *Element {
+	return e.prev
+}
+
 func (t *SkipList) Put(key []byte, value interface{}) *Element {
 	var element *Element
 	prev := t.backNodes(key)
-	if element = prev[0].next[0]; element != nil && bytes.Compare(key, element.key) <= 0 {
+	if element = prev[0].next[0]; element != nil && bytes.Compare(key, element.key) == 0 {
 		element.value = value
 		return element
 	}
 	element = &Element{
-		Node{next: make([]*Element, t.randomLevel())},
-		key, value,
+		Node:  Node{next: make([]*Element, t.randomLevel())},
+		key:   key,
+		value: value,
 	}
 	for i := range element.next {
 		element.next[i] = prev[i].next[i]
 		prev[i].next[i] = element
 	}
+	if next := element.next[0]; next != nil {
+		element.prev = next.prev
+		next.prev = element
+	} else {
+		element.prev = t.tail
+		t.tail = element
+	}
 	t.Len++
 	return element
 }
@@ -74,12 +89,17 @@ func (t *SkipList) Exist(key []byte) bool {
 	return t.Get(key) != nil
 }
 
-func (t SkipList) Remove(key []byte) *Element {
+func (t *SkipList) Remove(key []byte) *Element {
 	prev := t.backNodes(key)
-	if element := prev[0].next[0]; element != nil && bytes.Compare(element.key, key) <= 0 {
+	if element := prev[0].next[0]; element != nil && bytes.Compare(element.key, key) == 0 {
 		for k, v := range element.next {
 			prev[k].next[k] = v
 		}
+		if next := element.next[0]; next != nil {
+			next.prev = element.prev
+		} else {
+			t.tail = element.prev
+		}
 		t.Len--
 		return element
 	}
@@ -109,6 +129,20 @@ func (t *SkipList) backNodes(key []byte) []*Node {
 	return prevs
 }
 
+// Seek 返回第一个大于等于 key 的节点，不存在时返回 nil
+func (t *SkipList) Seek(key []byte) *Element {
+	var prev = &t.Node
+	var next *Element
+	for i := t.maxLevel - 1; i >= 0; i-- {
+		next = prev.next[i]
+		for next != nil && bytes.Compare(key, next.key) > 0 {
+			prev = &next.Node
+			next = next.next[i]
+		}
+	}
+	return next
+}
+
 func (t *SkipList) FindPrefix(prefix []byte) *Element {
 	var prev = &t.Node
 	var next *Element
@@ -149,6 +183,11 @@ func (t *SkipList) Front() *Element {
 	return t.next[0]
 }
 
+// Back 返回最后一个节点
+func (t *SkipList) Back() *Element {
+	return t.tail
+}
+
 func (t *SkipList) Get(key []byte) *Element {
 	var prev = &t.Node
 	var next *Element
@@ -0,0 +1,180 @@
+package KV_Storage
+
+import (
+	"KV_Storage/index"
+	"bytes"
+)
+
+// IteratorOptions 字符串迭代器的选项
+type IteratorOptions struct {
+	LowerBound []byte // 迭代的下界（包含），为空时不限制
+	UpperBound []byte // 迭代的上界（不包含），为空时不限制
+	Reverse    bool   // 是否按key从大到小的顺序迭代
+	KeyOnly    bool   // 只迭代key，此时 Value 总是返回 nil
+}
+
+// Iterator 按key的顺序遍历字符串数据的迭代器，已过期的key会被跳过
+// 迭代器不是快照，也不会一直持有索引的锁，迭代期间的写入可能会被看到，也可能不会
+type Iterator struct {
+	db   *KvDB
+	opts IteratorOptions
+	node *index.Element
+}
+
+// NewIterator 新建一个字符串迭代器，并定位到迭代方向上的第一个key
+func (db *KvDB) NewIterator(opts IteratorOptions) *Iterator {
+	it := &Iterator{db: db, opts: opts}
+	it.Rewind()
+	return it
+}
+
+// Rewind 定位到迭代方向上的第一个key，即正序时最小的key，逆序时最大的key
+func (it *Iterator) Rewind() {
+	if it.opts.Reverse {
+		it.SeekToLast()
+	} else {
+		it.SeekToFirst()
+	}
+}
+
+// SeekToFirst 定位到范围内最小的key
+func (it *Iterator) SeekToFirst() {
+	it.db.strIndex.mu.RLock()
+	defer it.db.strIndex.mu.RUnlock()
+
+	skl := it.db.strIndex.idxList
+	node := skl.Front()
+	if it.opts.LowerBound != nil {
+		node = skl.Seek(it.opts.LowerBound)
+	}
+	it.settle(node, true)
+}
+
+// SeekToLast 定位到范围内最大的key
+func (it *Iterator) SeekToLast() {
+	it.db.strIndex.mu.RLock()
+	defer it.db.strIndex.mu.RUnlock()
+
+	node := it.db.strIndex.idxList.Back()
+	if it.opts.UpperBound != nil {
+		node = it.seekLess(it.opts.UpperBound, false)
+	}
+	it.settle(node, false)
+}
+
+// Seek 正序时定位到第一个大于等于 key 的key，逆序时定位到最后一个小于等于 key 的key
+func (it *Iterator) Seek(key []byte) {
+	it.db.strIndex.mu.RLock()
+	defer it.db.strIndex.mu.RUnlock()
+
+	lower, upper := it.opts.LowerBound, it.opts.UpperBound
+	if !it.opts.Reverse {
+		if lower != nil && bytes.Compare(key, lower) < 0 {
+			key = lower
+		}
+		it.settle(it.db.strIndex.idxList.Seek(key), true)
+		return
+	}
+
+	if upper != nil && bytes.Compare(key, upper) >= 0 {
+		it.settle(it.seekLess(upper, false), false)
+		return
+	}
+	it.settle(it.seekLess(key, true), false)
+}
+
+// Next 沿迭代方向移动到下一个key
+func (it *Iterator) Next() {
+	it.step(!it.opts.Reverse)
+}
+
+// Prev 沿迭代方向的反方向移动到上一个key
+func (it *Iterator) Prev() {
+	it.step(it.opts.Reverse)
+}
+
+// Valid 判断迭代器当前是否指向一个有效的key
+func (it *Iterator) Valid() bool {
+	return it.node != nil
+}
+
+// Key 返回当前的key，调用方不能修改返回的数据
+func (it *Iterator) Key() []byte {
+	if it.node == nil {
+		return nil
+	}
+	return it.node.Key()
+}
+
+// Value 返回当前key对应的value，只有key存于内存时才会在调用时从数据文件中读取
+func (it *Iterator) Value() ([]byte, error) {
+	if it.node == nil || it.opts.KeyOnly {
+		return nil, nil
+	}
+
+	idx := it.node.Value().(*index.Indexer)
+	if idx == nil {
+		return nil, ErrNilIndexer
+	}
+	if it.db.config.IdxMode == KeyValueRamMode {
+		return idx.Meta.Value, nil
+	}
+
+	it.db.strIndex.mu.RLock()
+	defer it.db.strIndex.mu.RUnlock()
+	return it.db.readValue(String, idx)
+}
+
+// Close 关闭迭代器
+func (it *Iterator) Close() {
+	it.node = nil
+}
+
+func (it *Iterator) step(forward bool) {
+	if it.node == nil {
+		return
+	}
+
+	it.db.strIndex.mu.RLock()
+	defer it.db.strIndex.mu.RUnlock()
+
+	if forward {
+		it.settle(it.node.Next(), true)
+	} else {
+		it.settle(it.node.Prev(), false)
+	}
+}
+
+// 返回最后一个小于 key（inclusive 为 true 时小于等于）的节点
+func (it *Iterator) seekLess(key []byte, inclusive bool) *index.Element {
+	skl := it.db.strIndex.idxList
+	node := skl.Seek(key)
+	if node == nil {
+		return skl.Back()
+	}
+	if inclusive && bytes.Equal(node.Key(), key) {
+		return node
+	}
+	return node.Prev()
+}
+
+// 从 node 开始沿指定方向跳过已过期的key，超出边界时迭代结束，调用方需持有 strIndex 的读锁
+func (it *Iterator) settle(node *index.Element, forward bool) {
+	for node != nil && it.db.isExpired(node.Key(), String) {
+		if forward {
+			node = node.Next()
+		} else {
+			node = node.Prev()
+		}
+	}
+
+	if node != nil {
+		key := node.Key()
+		if it.opts.LowerBound != nil && bytes.Compare(key, it.opts.LowerBound) < 0 {
+			node = nil
+		} else if it.opts.UpperBound != nil && bytes.Compare(key, it.opts.UpperBound) >= 0 {
+			node = nil
+		}
+	}
+	it.node = node
+}
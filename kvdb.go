@@ -1,22 +1,24 @@
 package KV_Storage
 
 import (
+	"KV_Storage/cache"
 	"KV_Storage/index"
 	"KV_Storage/storage"
 	"KV_Storage/utils"
 	"bytes"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"io"
 	"os"
+	"sort"
 	"sync"
-	"time"
 )
 
 var (
 	ErrEmptyKey         = errors.New("kvdb: the key is empty")
 	ErrKeyTooLarge      = errors.New("kvdb: key exceeded the max length")
 	ErrValueTooLarge    = errors.New("kbdb: value exceeded the max length")
+	ErrEntryTooLarge    = errors.New("kvdb: entry exceeded the block size")
 	ErrKeyNotExist      = errors.New("kvdb: key not exist")
 	ErrNilIndexer       = errors.New("kvdb: indexer is nil")
 	ErrCfgNotExist      = errors.New("kvdb: the config file not exist")
@@ -26,6 +28,29 @@ var (
 
 	ErrInvalidTTL = errors.New("mindb: invalid ttl")
 	ErrKeyExpired = errors.New("kvdb: key is expired")
+
+	ErrInvalidSetOptions = errors.New("kvdb: invalid set options")
+
+	ErrValueNotInteger = errors.New("kvdb: value is not an integer or out of range")
+	ErrValueNotFloat   = errors.New("kvdb: value is not a valid float")
+	ErrIncrOverflow    = errors.New("kvdb: increment or decrement would overflow")
+
+	ErrInvalidOffset       = errors.New("kvdb: offset is out of range")
+	ErrInvalidGetExOptions = errors.New("kvdb: invalid getex options")
+
+	ErrInvalidBit   = errors.New("kvdb: bit is not an integer or out of range")
+	ErrInvalidBitOp = errors.New("kvdb: invalid bitop operation")
+
+	ErrInvalidCoordinates = errors.New("kvdb: invalid longitude or latitude")
+	ErrInvalidGeoUnit     = errors.New("kvdb: unsupported unit, use m, km, ft or mi")
+	ErrInvalidGeoSearch   = errors.New("kvdb: invalid geosearch options")
+	ErrGeoMemberNotExist  = errors.New("kvdb: could not decode requested zset member")
+
+	ErrInvalidStreamFields = errors.New("kvdb: wrong number of stream fields or keys")
+
+	ErrInvalidTimeout  = errors.New("kvdb: timeout is negative or out of range")
+	ErrInvalidLPosArgs = errors.New("kvdb: rank can't be zero, count and maxlen can't be negative")
+	ErrDBClosed        = errors.New("kvdb: database is closed")
 )
 
 const (
@@ -46,10 +71,15 @@ type (
 		hashIndex     *HashIdx
 		setIndex      *SetIdx
 		zsetIndex     *ZsetIdx
+		streamIndex   *StreamIdx
+		jsonIndex     *JSONIdx
 		config        Config
 		mu            sync.RWMutex
 		meta          *storage.DBMeta
-		expires       storage.Expires
+		expires       map[DataType]storage.Expires // 不同类型的过期字典
+		cache         *cache.Cache                 // KeyOnlyRamMode 下从数据文件读取的 value 缓存
+		expireDone    chan struct{}
+		expireWg      sync.WaitGroup
 	}
 
 	ActiveFiles   map[DataType]*storage.DBFile
@@ -75,6 +105,15 @@ func Open(config Config) (*KvDB, error) {
 		return nil, err
 	}
 
+	// 加载已封存数据文件的布隆过滤器
+	for dataType, files := range archFiles {
+		for _, file := range files {
+			if err := file.LoadBloomFilter(config.DirPath, dataType, entryKeys); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// 加载活跃文件
 	activeFiles := make(ActiveFiles)
 	for dataType, fileId := range activeFileIds { // 遍历每一种类型的活跃文件
@@ -86,7 +125,10 @@ func Open(config Config) (*KvDB, error) {
 	}
 
 	// 加载过期字典
-	expires := storage.LoadExpires(config.DirPath + expireFile)
+	expires := make(map[DataType]storage.Expires)
+	for dataType := range storage.DBFileFormatNames {
+		expires[dataType] = storage.LoadExpires(expireFilePath(config.DirPath, dataType))
+	}
 
 	// 加载数据库额外信息（meta）
 	meta, _ := storage.LoadMeta(config.DirPath + dbMetaSaveFile)
@@ -107,14 +149,24 @@ func Open(config Config) (*KvDB, error) {
 		hashIndex:     newHashIdx(),
 		setIndex:      newSetIdx(),
 		zsetIndex:     newZsetIdx(),
+		streamIndex:   newStreamIdx(),
+		jsonIndex:     newJSONIdx(),
 		expires:       expires,
 	}
 
+	// 只有 key 存于内存时，读取 value 需要访问磁盘，此时启用 value 缓存
+	if config.IdxMode == KeyOnlyRamMode && config.CacheCapacity > 0 {
+		db.cache = cache.New(config.CacheCapacity, config.CacheShards)
+	}
+
 	// 从文件中加载索引信息
 	if err := db.loadIdxFromFiles(); err != nil {
 		return nil, err
 	}
 
+	// 启动主动过期任务
+	db.startActiveExpire()
+
 	return db, nil
 }
 
@@ -126,7 +178,7 @@ func Reopen(path string) (*KvDB, error) {
 
 	var config Config
 
-	bytes, err := ioutil.ReadFile(path + configSaveFile)
+	bytes, err := utils.ReadFileChecked(path + configSaveFile)
 	if err != nil {
 		return nil, err
 	}
@@ -141,6 +193,9 @@ func (db *KvDB) Close() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	db.stopActiveExpire()
+	db.cancelListWaiters()
+
 	if err := db.saveConfig(); err != nil {
 		return err
 	}
@@ -149,8 +204,10 @@ func (db *KvDB) Close() error {
 		return err
 	}
 
-	if err := db.expires.SaveExpires(db.config.DirPath + expireFile); err != nil { // 保存过期信息
-		return err
+	for dataType, expires := range db.expires { // 保存过期信息
+		if err := expires.SaveExpires(expireFilePath(db.config.DirPath, dataType)); err != nil {
+			return err
+		}
 	}
 
 	// close and sync the active file
@@ -173,20 +230,32 @@ func (db *KvDB) Close() error {
 }
 
 // 关闭数据库之前保存配置
-func (db *KvDB) saveConfig() (err error) {
+func (db *KvDB) saveConfig() error {
 	//保存配置
 	path := db.config.DirPath + configSaveFile
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
-
 	bytes, err := json.Marshal(db.config)
-	_, err = file.Write(bytes)
-	err = file.Close()
+	if err != nil {
+		return err
+	}
 
-	return
+	return utils.WriteFileAtomic(path, bytes, 0600)
+}
+
+// 过期字典的保存路径，为了兼容旧版本，字符串类型仍然保存在 db.expires 中
+func expireFilePath(dirPath string, dataType DataType) string {
+	if dataType == String {
+		return dirPath + expireFile
+	}
+	return dirPath + expireFile + "." + storage.DBFileSuffixName[dataType]
 }
 
 // 持久化数据库信息
+// 写入数据时只持有相应类型的锁，不同类型并发写入同一个 map 会产生竞争，因此写偏移在保存时才从活跃文件中获取
 func (db *KvDB) saveMeta() error {
+	for dataType, file := range db.activeFile {
+		db.meta.ActiveWriteOff[dataType] = file.Offset
+	}
+
 	metaPath := db.config.DirPath + dbMetaSaveFile
 	return db.meta.Store(metaPath)
 }
@@ -198,6 +267,13 @@ func (db *KvDB) buildIndex(e *storage.Entry, idx *index.Indexer) error {
 		idx.Meta.Value = e.Meta.Value
 		idx.Meta.ValueSize = uint32(len(e.Meta.Value))
 	}
+
+	// 设置和清除过期时间的操作
+	if expireMark, persistMark := expireMarks(e.Type); e.Mark == expireMark || e.Mark == persistMark {
+		db.buildExpireIndex(idx, e.Type, e.Mark)
+		return nil
+	}
+
 	switch e.Type {
 	case storage.String: // 如果是string，就把当前索引加入到跳表中
 		db.buildStringIndex(idx, e.Mark)
@@ -209,6 +285,10 @@ func (db *KvDB) buildIndex(e *storage.Entry, idx *index.Indexer) error {
 		db.buildSetIndex(idx, e.Mark)
 	case storage.ZSet:
 		db.buildZsetIndex(idx, e.Mark)
+	case storage.Stream:
+		db.buildStreamIndex(idx, e.Mark)
+	case storage.JSON:
+		db.buildJSONIndex(idx, e.Mark)
 	}
 
 	return nil
@@ -217,13 +297,23 @@ func (db *KvDB) buildIndex(e *storage.Entry, idx *index.Indexer) error {
 // 写数据
 func (db *KvDB) store(e *storage.Entry) error {
 
-	//如果数据文件空间不够，则持久化该文件，并新打开一个文件
+	// 单条entry不能跨越数据文件，超过 BlockSize 时 MMap 模式下会被截断，之后无法通过校验
 	config := db.config
+	if int64(e.Size()) > config.BlockSize {
+		return ErrEntryTooLarge
+	}
+
+	//如果数据文件空间不够，则持久化该文件，并新打开一个文件
 	if db.activeFile[e.Type].Offset+int64(e.Size()) > config.BlockSize {
 		if err := db.activeFile[e.Type].Sync(); err != nil {
 			return err
 		}
 
+		// 封存文件，为其构建布隆过滤器
+		if err := db.activeFile[e.Type].Seal(config.DirPath, e.Type, entryKeys); err != nil {
+			return err
+		}
+
 		//保存旧的文件
 		activeFileId := db.activeFileIds[e.Type]
 		db.archFiles[e.Type][activeFileId] = db.activeFile[e.Type]
@@ -235,7 +325,6 @@ func (db *KvDB) store(e *storage.Entry) error {
 		}
 		db.activeFile[e.Type] = newDbFile
 		db.activeFileIds[e.Type] = activeFileId
-		db.meta.ActiveWriteOff[e.Type] = 0
 	}
 	//
 	////如果key已经存在，则原来的值被舍弃，所以需要新增可回收的磁盘空间值
@@ -251,8 +340,6 @@ func (db *KvDB) store(e *storage.Entry) error {
 		return err
 	}
 
-	db.meta.ActiveWriteOff[e.Type] = db.activeFile[e.Type].Offset
-
 	// 数据持久化
 	if config.Sync {
 		if err := db.activeFile[e.Type].Sync(); err != nil {
@@ -263,6 +350,125 @@ func (db *KvDB) store(e *storage.Entry) error {
 	return nil
 }
 
+// FilesMayContain 返回可能包含 key 的已封存数据文件id（升序）
+// 借助每个文件的布隆过滤器，不包含 key 的文件可以直接跳过
+func (db *KvDB) FilesMayContain(dataType DataType, key []byte) (fileIds []uint32) {
+	mu := db.idxLock(dataType)
+	if mu == nil {
+		return
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for id, df := range db.archFiles[dataType] {
+		if df.MayContain(key) {
+			fileIds = append(fileIds, id)
+		}
+	}
+	sort.Slice(fileIds, func(i, j int) bool { return fileIds[i] < fileIds[j] })
+	return
+}
+
+// ArchivedFilesContaining 返回包含与 key 相关的 entry 的已封存数据文件id（升序）
+// 只扫描布隆过滤器判断可能包含 key 的文件，并逐条确认，因此结果中没有误判
+func (db *KvDB) ArchivedFilesContaining(dataType DataType, key []byte) (fileIds []uint32, err error) {
+	mu := db.idxLock(dataType)
+	if mu == nil {
+		return
+	}
+
+	for _, id := range db.FilesMayContain(dataType, key) {
+		mu.RLock()
+		found := false
+		if df, ok := db.archFiles[dataType][id]; ok { // 期间文件可能已被 FlushDB 删除
+			found, err = fileContains(df, key)
+		}
+		mu.RUnlock()
+
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			fileIds = append(fileIds, id)
+		}
+	}
+	return
+}
+
+// 顺序扫描数据文件，判断其中是否有与 key 相关的 entry
+func fileContains(df *storage.DBFile, key []byte) (bool, error) {
+	scanner := df.NewScanner()
+	for {
+		e, _, err := scanner.Next()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, k := range entryKeys(e) {
+			if bytes.Equal(k, key) {
+				return true, nil
+			}
+		}
+	}
+}
+
+// 返回保护指定数据类型索引的锁，同一类型的数据文件也由该锁保护
+func (db *KvDB) idxLock(dataType DataType) *sync.RWMutex {
+	switch dataType {
+	case String:
+		return &db.strIndex.mu
+	case List:
+		return &db.listIndex.mu
+	case Hash:
+		return &db.hashIndex.mu
+	case Set:
+		return &db.setIndex.mu
+	case ZSet:
+		return &db.zsetIndex.mu
+	case Stream:
+		return &db.streamIndex.mu
+	case JSON:
+		return &db.jsonIndex.mu
+	}
+	return nil
+}
+
+// CacheStats 返回 value 缓存的命中统计信息，未启用缓存时返回零值
+func (db *KvDB) CacheStats() cache.Stats {
+	if db.cache == nil {
+		return cache.Stats{}
+	}
+	return db.cache.Stats()
+}
+
+// 根据索引信息从数据文件中读取 value，启用缓存时优先从缓存中获取
+func (db *KvDB) readValue(dataType DataType, idx *index.Indexer) ([]byte, error) {
+	key := cache.Key{Type: dataType, FileId: idx.FileId, Offset: idx.Offset}
+	if db.cache != nil {
+		if val, ok := db.cache.Get(key); ok {
+			return val, nil
+		}
+	}
+
+	df := db.activeFile[dataType]
+	if idx.FileId != db.activeFileIds[dataType] {
+		df = db.archFiles[dataType][idx.FileId]
+	}
+
+	// 索引中记录了 entry 的大小，一次读取即可
+	e, err := df.ReadEntry(idx.Offset, idx.EntrySize)
+	if err != nil {
+		return nil, err
+	}
+
+	if db.cache != nil {
+		db.cache.Put(key, e.Meta.Value)
+	}
+	return e.Meta.Value, nil
+}
+
 // 检查key value是否符合规范
 func (db *KvDB) checkKeyValue(key []byte, value ...[]byte) error {
 	keySize := uint32(len(key))
@@ -284,6 +490,35 @@ func (db *KvDB) checkKeyValue(key []byte, value ...[]byte) error {
 	return nil
 }
 
+// 判断由多个值合并而成的 entry 能否作为一条日志写入，即 value 不超过 MaxValueSize 且 entry 能放入一个数据文件
+func (db *KvDB) fitsInEntry(e *storage.Entry) bool {
+	return uint64(len(e.Meta.Value)) <= uint64(db.config.MaxValueSize) && int64(e.Size()) <= db.config.BlockSize
+}
+
+// 将 n 个元素按顺序分批写入 key 的多条日志，size(i) 为第 i 个元素编码后的长度，base 为每条日志中公共部分的长度
+// 返回每批的结束位置，每批的 value 同时不超过 MaxValueSize 和数据文件的大小，单个元素也无法放入时返回 ErrValueTooLarge
+func (db *KvDB) entryBatches(key []byte, base, n int, size func(i int) int) ([]int, error) {
+	limit := db.config.BlockSize - int64(storage.NewEntryNoExtra(key, nil, 0, 0).Size())
+	if limit > int64(db.config.MaxValueSize) {
+		limit = int64(db.config.MaxValueSize)
+	}
+
+	var ends []int
+	total := int64(base)
+	for i := 0; i < n; i++ {
+		s := int64(size(i))
+		if int64(base)+s > limit {
+			return nil, ErrValueTooLarge
+		}
+		if total+s > limit {
+			ends = append(ends, i)
+			total = int64(base)
+		}
+		total += s
+	}
+	return append(ends, n), nil
+}
+
 func (db *KvDB) validEntry(e *storage.Entry, offset int64, fileId uint32) bool {
 	if e == nil {
 		return false
@@ -294,8 +529,7 @@ func (db *KvDB) validEntry(e *storage.Entry, offset int64, fileId uint32) bool {
 	case String:
 		if mark == StringSet { // 如果本条entry是set操作，将其的值与当前最新的值进行比较
 			// 首先判断该entry中的key是否过期
-			now := uint32(time.Now().Unix()) // 得到当前时间的纳秒数
-			if deadline, exist := db.expires[string(e.Meta.Key)]; exist && deadline <= now {
+			if deadline, exist := db.expires[String][string(e.Meta.Key)]; exist && deadline <= nowMillis() {
 				return false // 从过期字典中取出当前key的过期时间，如果有过期时间且已过期，则该记录无效
 			}
 
@@ -0,0 +1,428 @@
+package KV_Storage
+
+import (
+	"KV_Storage/storage"
+	"fmt"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) *KvDB {
+	t.Helper()
+	config := DefaultConfig()
+	config.DirPath = t.TempDir()
+	config.ActiveExpireHz = 0
+	return openWithConfig(t, config)
+}
+
+func openWithConfig(t *testing.T, config Config) *KvDB {
+	t.Helper()
+	db, err := Open(config)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return db
+}
+
+// 重新打开数据库，crash 为 true 时不调用 Close，模拟异常退出后只能依靠重放数据文件恢复索引和过期时间
+func reopen(t *testing.T, db *KvDB, crash bool) *KvDB {
+	t.Helper()
+	if !crash {
+		if err := db.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+	return openWithConfig(t, db.config)
+}
+
+// 依次以正常关闭和异常退出两种方式重新打开数据库运行 fn
+func forEachReopen(t *testing.T, fn func(t *testing.T, crash bool)) {
+	for _, crash := range []bool{false, true} {
+		name := "close"
+		if crash {
+			name = "crash"
+		}
+		t.Run(name, func(t *testing.T) { fn(t, crash) })
+	}
+}
+
+func TestReopenKeepsTTL(t *testing.T) {
+	forEachReopen(t, func(t *testing.T, crash bool) {
+		db := openTestDB(t)
+		must(t, db.Set([]byte("str"), []byte("v")))
+		mustN(t)(db.RPush([]byte("list"), []byte("a")))
+		mustN(t)(db.HSet([]byte("hash"), []byte("f"), []byte("v")))
+		mustN(t)(db.SAdd([]byte("short"), []byte("m")))
+
+		must(t, db.Expire([]byte("str"), 100))
+		must(t, db.PExpire([]byte("list"), 50000))
+		must(t, db.Expire([]byte("hash"), 100))
+		must(t, db.Persist([]byte("hash")))
+		must(t, db.PExpire([]byte("short"), 20))
+		time.Sleep(50 * time.Millisecond)
+
+		db = reopen(t, db, crash)
+		defer db.Close()
+
+		if ttl := db.TTL([]byte("str")); ttl < 99 || ttl > 100 {
+			t.Errorf("TTL(str) = %d, want 100", ttl)
+		}
+		if ttl := db.PTTL([]byte("list")); ttl == 0 || ttl > 50000 {
+			t.Errorf("PTTL(list) = %d, want at most 50000", ttl)
+		}
+		if ttl := db.TTL([]byte("hash")); ttl != 0 {
+			t.Errorf("TTL(hash) = %d after Persist, want 0", ttl)
+		}
+		if v := db.HGet([]byte("hash"), []byte("f")); string(v) != "v" {
+			t.Errorf("HGet(hash) = %q", v)
+		}
+		if db.SIsMember([]byte("short"), []byte("m")) {
+			t.Error("expired set is still visible after reopening")
+		}
+		if v, err := db.Get([]byte("str")); err != nil || string(v) != "v" {
+			t.Errorf("Get(str) = %q, %v", v, err)
+		}
+	})
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustN(t *testing.T) func(int, error) {
+	return func(_ int, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func assertList(t *testing.T, db *KvDB, key string, want ...string) {
+	t.Helper()
+	got, err := db.LRange([]byte(key), 0, -1)
+	if err != nil && len(want) > 0 {
+		t.Fatalf("LRange(%s): %v", key, err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("LRange(%s) = %q, want %q", key, got, want)
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Fatalf("LRange(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestReopenKeepsLMove(t *testing.T) {
+	forEachReopen(t, func(t *testing.T, crash bool) {
+		db := openTestDB(t)
+		mustN(t)(db.RPush([]byte("src"), []byte("a"), []byte("b"), []byte("c")))
+		mustN(t)(db.RPush([]byte("dst"), []byte("x")))
+
+		moves := []struct {
+			src, dst string
+			from, to ListDirection
+			want     string
+		}{
+			{"src", "dst", ListLeft, ListRight, "a"},
+			{"src", "dst", ListRight, ListLeft, "c"},
+			{"dst", "dst", ListLeft, ListRight, "c"}, // 同一个列表内的轮转
+			{"src", "new", ListLeft, ListLeft, "b"},  // src 被取空后删除，dst 不存在时新建
+		}
+		for _, m := range moves {
+			v, err := db.LMove([]byte(m.src), []byte(m.dst), m.from, m.to)
+			if err != nil || string(v) != m.want {
+				t.Fatalf("LMove(%s, %s) = %q, %v, want %q", m.src, m.dst, v, err, m.want)
+			}
+		}
+
+		db = reopen(t, db, crash)
+		defer db.Close()
+
+		assertList(t, db, "dst", "x", "a", "c")
+		assertList(t, db, "new", "b")
+		if db.LKeyExists([]byte("src")) {
+			t.Error("emptied source list exists after reopening")
+		}
+	})
+}
+
+func assertSet(t *testing.T, db *KvDB, key string, want ...string) {
+	t.Helper()
+	var got []string
+	for _, m := range db.SMembers([]byte(key)) {
+		got = append(got, string(m))
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("SMembers(%s) = %q, want %q", key, got, want)
+	}
+}
+
+func TestReopenKeepsSStore(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		maxValueSize uint32
+	}{
+		{"single entry", DefaultMaxValueSize},
+		{"per member", 16}, // 结果集超出 value 的大小限制，退化为 SClear 加逐个 SAdd
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			forEachReopen(t, func(t *testing.T, crash bool) {
+				config := DefaultConfig()
+				config.DirPath = t.TempDir()
+				config.ActiveExpireHz = 0
+				config.MaxValueSize = tc.maxValueSize
+				db := openWithConfig(t, config)
+
+				var a, b []string
+				for i := 0; i < 10; i++ {
+					a = append(a, fmt.Sprintf("m%02d", i))
+					b = append(b, fmt.Sprintf("m%02d", i+5))
+				}
+				for i := range a {
+					mustN(t)(db.SAdd([]byte("a"), []byte(a[i])))
+					mustN(t)(db.SAdd([]byte("b"), []byte(b[i])))
+				}
+				mustN(t)(db.SAdd([]byte("dst"), []byte("old")))
+				must(t, db.Expire([]byte("dst"), 100))
+				mustN(t)(db.SAdd([]byte("gone"), []byte("old")))
+
+				if n, err := db.SUnionStore([]byte("dst"), []byte("a"), []byte("b")); err != nil || n != 15 {
+					t.Fatalf("SUnionStore = %d, %v", n, err)
+				}
+				if n, err := db.SInterStore([]byte("inter"), []byte("a"), []byte("b")); err != nil || n != 5 {
+					t.Fatalf("SInterStore = %d, %v", n, err)
+				}
+				if n, err := db.SDiffStore([]byte("gone"), []byte("a"), []byte("a")); err != nil || n != 0 {
+					t.Fatalf("SDiffStore = %d, %v", n, err)
+				}
+
+				db = reopen(t, db, crash)
+				defer db.Close()
+
+				assertSet(t, db, "dst", append(a, b[5:]...)...)
+				assertSet(t, db, "inter", b[:5]...)
+				if db.SCard([]byte("gone")) != 0 {
+					t.Error("an empty result should delete the destination")
+				}
+				if ttl := db.TTL([]byte("dst")); ttl != 0 {
+					t.Errorf("TTL(dst) = %d, want the store to clear it", ttl)
+				}
+			})
+		})
+	}
+}
+
+func TestReopenKeepsRename(t *testing.T) {
+	forEachReopen(t, func(t *testing.T, crash bool) {
+		db := openTestDB(t)
+		must(t, db.Set([]byte("k"), []byte("v")))
+		mustN(t)(db.RPush([]byte("k"), []byte("a"), []byte("b")))
+		mustN(t)(db.SAdd([]byte("k"), []byte("m")))
+		must(t, db.Expire([]byte("k"), 100))
+		mustN(t)(db.HSet([]byte("n"), []byte("f"), []byte("v")))
+
+		// newKey 原有的数据被覆盖
+		must(t, db.Rename([]byte("k"), []byte("n")))
+
+		db = reopen(t, db, crash)
+		defer db.Close()
+
+		if n := db.Exists([]byte("k")); n != 0 {
+			t.Error("the old key exists after reopening")
+		}
+		if v, err := db.Get([]byte("n")); err != nil || string(v) != "v" {
+			t.Errorf("Get(n) = %q, %v", v, err)
+		}
+		assertList(t, db, "n", "a", "b")
+		assertSet(t, db, "n", "m")
+		if v := db.HGet([]byte("n"), []byte("f")); v != nil {
+			t.Errorf("HGet(n) = %q, want the overwritten hash to be gone", v)
+		}
+		if ttl := db.TTL([]byte("n")); ttl < 99 || ttl > 100 {
+			t.Errorf("TTL(n) = %d, want the TTL to move with the key", ttl)
+		}
+	})
+}
+
+func TestArchivedFilesContaining(t *testing.T) {
+	config := DefaultConfig()
+	config.DirPath = t.TempDir()
+	config.ActiveExpireHz = 0
+	config.BlockSize = 4 * 1024
+	db := openWithConfig(t, config)
+
+	value := make([]byte, 100)
+	for i := 0; i < 200; i++ {
+		must(t, db.Set([]byte(fmt.Sprintf("key-%03d", i)), value))
+		mustN(t)(db.RPush([]byte(fmt.Sprintf("list-%03d", i)), value))
+	}
+	if _, err := db.LMove([]byte("list-000"), []byte("moved"), ListLeft, ListLeft); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		mustN(t)(db.RPush([]byte("filler"), value))
+	}
+
+	check := func(db *KvDB) {
+		t.Helper()
+		if n := len(db.archFiles[String]); n < 4 {
+			t.Fatalf("%d archived string files, want the writes to seal several", n)
+		}
+		for _, df := range db.archFiles[String] {
+			if df.Bloom == nil {
+				t.Fatalf("archived file %d has no bloom filter", df.Id)
+			}
+		}
+
+		if ids, err := db.ArchivedFilesContaining(String, []byte("key-000")); err != nil || len(ids) != 1 || ids[0] != 0 {
+			t.Errorf("ArchivedFilesContaining(key-000) = %v, %v, want [0]", ids, err)
+		}
+		// LMOVE 的目标列表只出现在 entry 的 value 中
+		if ids, err := db.ArchivedFilesContaining(List, []byte("moved")); err != nil || len(ids) != 1 {
+			t.Errorf("ArchivedFilesContaining(moved) = %v, %v, want one file", ids, err)
+		}
+
+		candidates := 0
+		for i := 0; i < 20; i++ {
+			key := []byte(fmt.Sprintf("missing-%d", i))
+			candidates += len(db.FilesMayContain(String, key))
+			if ids, err := db.ArchivedFilesContaining(String, key); err != nil || len(ids) != 0 {
+				t.Errorf("ArchivedFilesContaining(%s) = %v, %v", key, ids, err)
+			}
+		}
+		if total := 20 * len(db.archFiles[String]); candidates*10 > total {
+			t.Errorf("bloom filters kept %d of %d file lookups for missing keys", candidates, total)
+		}
+	}
+	check(db)
+
+	// 重新打开时从文件加载过滤器，过滤器文件丢失时重新构建
+	must(t, db.Close())
+	must(t, os.Remove(storage.BloomFilePath(config.DirPath, 1, String)))
+	db = openWithConfig(t, config)
+	defer db.Close()
+	check(db)
+	if _, err := os.Stat(storage.BloomFilePath(config.DirPath, 1, String)); err != nil {
+		t.Errorf("the missing bloom filter file was not rebuilt: %v", err)
+	}
+}
+
+func TestAppendValueSize(t *testing.T) {
+	config := DefaultConfig()
+	config.DirPath = t.TempDir()
+	config.ActiveExpireHz = 0
+	config.MaxValueSize = 8
+	db := openWithConfig(t, config)
+	defer db.Close()
+
+	must(t, db.Append([]byte("k"), []byte("12345678")))
+	if err := db.Append([]byte("k"), []byte("9")); err != ErrValueTooLarge {
+		t.Fatalf("Append past MaxValueSize: err = %v, want ErrValueTooLarge", err)
+	}
+	if v, err := db.Get([]byte("k")); err != nil || string(v) != "12345678" {
+		t.Fatalf("Get = %q, %v, want the rejected Append to leave the value unchanged", v, err)
+	}
+}
+
+func TestStreamEntrySizeLimits(t *testing.T) {
+	forEachReopen(t, func(t *testing.T, crash bool) {
+		config := DefaultConfig()
+		config.DirPath = t.TempDir()
+		config.ActiveExpireHz = 0
+		config.RwMethod = storage.MMap
+		config.BlockSize = 4 * 1024
+		db := openWithConfig(t, config)
+
+		// 超出数据文件大小的消息被拒绝，而不是在 MMap 中被截断
+		if _, err := db.XAdd([]byte("s"), "*", []byte("f"), make([]byte, 5000)); err != ErrValueTooLarge {
+			t.Fatalf("XAdd of an oversized message: err = %v, want ErrValueTooLarge", err)
+		}
+
+		const n = 300
+		var ids []string
+		for i := 0; i < n; i++ {
+			id, err := db.XAdd([]byte("s"), "*", []byte("f"), []byte(fmt.Sprintf("v%d", i)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		must(t, db.XGroupCreate([]byte("s"), []byte("g"), "0", false))
+
+		// COUNT 为 0 时一次投递全部消息，未确认消息的记录超出一条日志的大小，分为多条记录
+		res, err := db.XReadGroup([]byte("g"), []byte("c"), [][]byte{[]byte("s")}, []string{">"}, 0, false)
+		if err != nil || len(res) != 1 || len(res[0].Entries) != n {
+			t.Fatalf("XReadGroup = %v, %v", res, err)
+		}
+		if acked, err := db.XAck([]byte("s"), []byte("g"), ids[:n/2]...); err != nil || acked != n/2 {
+			t.Fatalf("XAck = %d, %v", acked, err)
+		}
+
+		db = reopen(t, db, crash)
+		defer db.Close()
+
+		pending, err := db.XPending([]byte("s"), []byte("g"), "-", "+", 0, nil)
+		if err != nil || len(pending) != n-n/2 || pending[0].ID.String() != ids[n/2] {
+			t.Fatalf("XPending = %d entries, %v, want the last %d", len(pending), err, n-n/2)
+		}
+		res, err = db.XReadGroup([]byte("g"), []byte("c"), [][]byte{[]byte("s")}, []string{">"}, 0, false)
+		if err != nil || len(res) != 0 {
+			t.Fatalf("XReadGroup after reopening = %v, %v, want no new messages", res, err)
+		}
+	})
+}
+
+func TestDBSize(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	must(t, db.Set([]byte("a"), []byte("v")))
+	must(t, db.Set([]byte("b"), []byte("v")))
+	mustN(t)(db.RPush([]byte("a"), []byte("v")))
+	mustN(t)(db.RPush([]byte("c"), []byte("v")))
+	mustN(t)(db.SAdd([]byte("a"), []byte("v")))
+	mustN(t)(db.SAdd([]byte("c"), []byte("v")))
+	mustN(t)(db.SAdd([]byte("d"), []byte("v")))
+	mustN(t)(db.HSet([]byte("e"), []byte("f"), []byte("v")))
+	if got := db.DBSize(); got != 5 {
+		t.Fatalf("DBSize = %d, want 5", got)
+	}
+
+	// 已过期但尚未删除的key不计算在内，同名key在其它类型中仍然存在时照常计算
+	must(t, db.PExpire([]byte("b"), 1))
+	must(t, db.PExpire([]byte("c"), 1))
+	time.Sleep(10 * time.Millisecond)
+	mustN(t)(db.SAdd([]byte("b"), []byte("v")))
+	if got, want := db.DBSize(), len(db.Keys([]byte("*"))); got != want || got != 4 {
+		t.Fatalf("DBSize = %d, len(Keys) = %d, want 4", got, want)
+	}
+}
+
+func TestHIncrByFieldSize(t *testing.T) {
+	config := DefaultConfig()
+	config.DirPath = t.TempDir()
+	config.ActiveExpireHz = 0
+	config.MaxValueSize = 8
+	db := openWithConfig(t, config)
+	defer db.Close()
+
+	field := []byte("field-longer-than-8")
+	if _, err := db.HIncrBy([]byte("h"), field, 1); err != ErrValueTooLarge {
+		t.Errorf("HIncrBy: err = %v, want ErrValueTooLarge", err)
+	}
+	if _, err := db.HIncrByFloat([]byte("h"), field, 1.5); err != ErrValueTooLarge {
+		t.Errorf("HIncrByFloat: err = %v, want ErrValueTooLarge", err)
+	}
+	if v := db.HGet([]byte("h"), field); v != nil {
+		t.Errorf("HGet = %q, want the rejected increments to write nothing", v)
+	}
+}
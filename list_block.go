@@ -0,0 +1,222 @@
+package KV_Storage
+
+import (
+	"KV_Storage/storage"
+	"bytes"
+	"strconv"
+	"time"
+)
+
+// 阻塞式列表操作的实现
+// 列表为空时，客户端按阻塞的先后顺序在每个 key 上排队，LPush、RPush、LInsert 等加入元素的操作在持有写锁时直接为队首的客户端取出元素，
+// 因此被唤醒的客户端一定能拿到元素，不会被其他客户端抢走
+
+type (
+	// 阻塞在一个或多个列表上等待元素的客户端
+	listWaiter struct {
+		keys   [][]byte
+		from   ListDirection
+		dst    []byte // 不为 nil 时将取出的元素加入 dst 中
+		to     ListDirection
+		result chan listPopResult // 容量为1，客户端被服务或被取消时写入
+	}
+
+	listPopResult struct {
+		key   []byte
+		value []byte
+		err   error
+	}
+)
+
+// 依次检查 keys 中的列表，从第一个非空的列表中取出元素，dst 不为 nil 时将其加入 dst
+// 所有列表都为空时阻塞，直到有元素加入或超过 timeout，timeout 为0时一直阻塞，超时返回的 key 为 nil
+func (db *KvDB) blockingListPop(keys [][]byte, dst []byte, from, to ListDirection, timeout time.Duration) (key, val []byte, err error) {
+	if timeout < 0 {
+		return nil, nil, ErrInvalidTimeout
+	}
+	for _, k := range keys {
+		if err = db.checkKeyValue(k, nil); err != nil {
+			return
+		}
+	}
+	if dst != nil {
+		if err = db.checkKeyValue(dst, nil); err != nil {
+			return
+		}
+	}
+
+	db.listIndex.mu.Lock()
+	for _, k := range keys {
+		db.expireIfNeeded(k, List)
+		if db.listIndex.indexes.LLen(string(k)) == 0 {
+			continue
+		}
+
+		val, err = db.listPopOrMove(k, dst, from, to)
+		if err == nil && dst != nil {
+			db.signalListKeys(dst)
+		}
+		db.listIndex.mu.Unlock()
+		return k, val, err
+	}
+
+	if db.listIndex.closed {
+		db.listIndex.mu.Unlock()
+		return nil, nil, ErrDBClosed
+	}
+	w := &listWaiter{keys: keys, from: from, dst: dst, to: to, result: make(chan listPopResult, 1)}
+	db.addListWaiter(w)
+	db.listIndex.mu.Unlock()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	select {
+	case res := <-w.result:
+		return res.key, res.value, res.err
+	case <-timer:
+	}
+
+	// 超时的同时可能刚好被服务，结果和等待队列都在写锁下修改，持有锁之后两者必居其一
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+	select {
+	case res := <-w.result:
+		return res.key, res.value, res.err
+	default:
+		db.removeListWaiter(w)
+		return nil, nil, nil
+	}
+}
+
+// 从列表 key 的一端取出元素，dst 不为 nil 时将其加入 dst 的一端，并作为一条日志记录，调用方需持有 listIndex 的写锁且 key 非空
+func (db *KvDB) listPopOrMove(key, dst []byte, from, to ListDirection) ([]byte, error) {
+	k := string(key)
+	idx := 0
+	if from == ListRight {
+		idx = db.listIndex.indexes.LLen(k) - 1
+	}
+	val := db.listIndex.indexes.LIndex(k, idx)
+
+	var e *storage.Entry
+	switch {
+	case dst != nil:
+		db.expireIfNeeded(dst, List)
+		var extra bytes.Buffer
+		extra.WriteString(strconv.Itoa(int(from)))
+		extra.WriteString(ExtraSeparator)
+		extra.WriteString(strconv.Itoa(int(to)))
+		e = storage.NewEntry(key, dst, extra.Bytes(), List, ListLMove)
+	case from == ListLeft:
+		e = storage.NewEntryNoExtra(key, val, List, ListLPop)
+	default:
+		e = storage.NewEntryNoExtra(key, val, List, ListRPop)
+	}
+	if err := db.store(e); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case dst != nil:
+		db.listIndex.move(k, string(dst), from, to)
+	case from == ListLeft:
+		db.listIndex.indexes.LPop(k)
+	default:
+		db.listIndex.indexes.RPop(k)
+	}
+	db.clearExpireIfEmpty(key, List)
+	return val, nil
+}
+
+// 在索引中将列表 src 一端的元素移动到列表 dst 的一端，用于执行和重放 ListLMove
+func (li *ListIdx) move(src, dst string, from, to ListDirection) {
+	if li.indexes.LLen(src) == 0 {
+		return
+	}
+
+	var val []byte
+	if from == ListLeft {
+		val = li.indexes.LPop(src)
+	} else {
+		val = li.indexes.RPop(src)
+	}
+	if to == ListLeft {
+		li.indexes.LPush(dst, val)
+	} else {
+		li.indexes.RPush(dst, val)
+	}
+}
+
+// 列表 keys 中加入了新的元素，按阻塞的先后顺序为等待这些列表的客户端取出元素，调用方需持有 listIndex 的写锁
+// 为 BLMove 的客户端服务时会向目标列表加入元素，目标列表上等待的客户端也会依次被服务
+func (db *KvDB) signalListKeys(keys ...[]byte) {
+	ready := append([][]byte(nil), keys...)
+	for len(ready) > 0 {
+		key := ready[0]
+		ready = ready[1:]
+
+		for {
+			queue := db.listIndex.waiters[string(key)]
+			if len(queue) == 0 || db.listIndex.indexes.LLen(string(key)) == 0 {
+				break
+			}
+
+			w := queue[0]
+			db.removeListWaiter(w)
+			val, err := db.listPopOrMove(key, w.dst, w.from, w.to)
+			w.result <- listPopResult{key: key, value: val, err: err}
+			if err == nil && w.dst != nil {
+				ready = append(ready, w.dst)
+			}
+		}
+	}
+}
+
+// 将客户端加入其等待的每个列表的队尾，调用方需持有 listIndex 的写锁
+func (db *KvDB) addListWaiter(w *listWaiter) {
+	for _, key := range w.keys {
+		k := string(key)
+		db.listIndex.waiters[k] = append(db.listIndex.waiters[k], w)
+	}
+}
+
+// 将客户端从其等待的所有列表的队列中移除，调用方需持有 listIndex 的写锁
+func (db *KvDB) removeListWaiter(w *listWaiter) {
+	for _, key := range w.keys {
+		k := string(key)
+		queue := db.listIndex.waiters[k]
+		n := 0
+		for _, other := range queue {
+			if other != w {
+				queue[n] = other
+				n++
+			}
+		}
+		if n == 0 {
+			delete(db.listIndex.waiters, k)
+		} else {
+			db.listIndex.waiters[k] = queue[:n]
+		}
+	}
+}
+
+// 关闭数据库时取消所有阻塞的客户端，它们会收到 ErrDBClosed，之后的阻塞操作在需要阻塞时也会直接返回 ErrDBClosed
+func (db *KvDB) cancelListWaiters() {
+	db.listIndex.mu.Lock()
+	defer db.listIndex.mu.Unlock()
+
+	db.listIndex.closed = true
+	for _, queue := range db.listIndex.waiters {
+		for _, w := range queue {
+			select {
+			case w.result <- listPopResult{err: ErrDBClosed}:
+			default: // 在多个列表上等待的客户端已经被取消过了
+			}
+		}
+	}
+	db.listIndex.waiters = make(map[string][]*listWaiter)
+}
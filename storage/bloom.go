@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"KV_Storage/utils"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"math"
+)
+
+var (
+	ErrInvalidBloomFilter = errors.New("storage/bloom: invalid bloom filter data")
+)
+
+const (
+	// DefaultBloomFalsePositive 布隆过滤器默认的误判率
+	DefaultBloomFalsePositive = 0.01
+
+	// BloomFileFormatName 布隆过滤器文件名，与对应的数据文件id和类型相同
+	// 注意文件名中不能包含 data，否则会在 Build 时被识别为数据文件
+	BloomFileFormatName = "%09d.bloom.%s"
+
+	// m uint64 + k uint32 + crc32 uint32
+	bloomHeaderSize = 16
+)
+
+// EntryKeys 返回一条 entry 涉及的全部 key，除了 entry 自身的 key，还包括 LMOVE、SMOVE、RENAME 等操作记录在 value 或 extra 中的目标 key
+type EntryKeys func(e *Entry) [][]byte
+
+// BloomFilter 基于 key 的布隆过滤器，封存的数据文件各自对应一个，用于快速判断某个 key 一定不在该文件中
+type BloomFilter struct {
+	bits []uint64
+	m    uint64 // 位数组的长度
+	k    uint32 // 哈希函数的个数
+}
+
+// NewBloomFilter 根据预计的元素个数 n 和误判率 fpRate 新建一个布隆过滤器
+func NewBloomFilter(n int, fpRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = DefaultBloomFalsePositive
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &BloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// Add 将 key 加入到布隆过滤器中
+func (b *BloomFilter) Add(key []byte) {
+	b.addHash(bloomHash(key))
+}
+
+// MayContain 判断 key 是否可能存在，返回 false 时 key 一定不存在
+func (b *BloomFilter) MayContain(key []byte) bool {
+	h1, h2 := splitHash(bloomHash(key))
+	for i := uint32(0); i < b.k; i++ {
+		pos := (h1 + uint64(i)*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode 编码布隆过滤器
+func (b *BloomFilter) Encode() []byte {
+	buf := make([]byte, bloomHeaderSize+len(b.bits)*8)
+	binary.BigEndian.PutUint64(buf[0:8], b.m)
+	binary.BigEndian.PutUint32(buf[8:12], b.k)
+	for i, w := range b.bits {
+		binary.BigEndian.PutUint64(buf[bloomHeaderSize+i*8:], w)
+	}
+	binary.BigEndian.PutUint32(buf[12:16], crc32.ChecksumIEEE(buf[bloomHeaderSize:]))
+	return buf
+}
+
+// DecodeBloomFilter 解码布隆过滤器
+func DecodeBloomFilter(buf []byte) (*BloomFilter, error) {
+	if len(buf) < bloomHeaderSize {
+		return nil, ErrInvalidBloomFilter
+	}
+
+	m := binary.BigEndian.Uint64(buf[0:8])
+	k := binary.BigEndian.Uint32(buf[8:12])
+	crc := binary.BigEndian.Uint32(buf[12:16])
+	words := (m + 63) / 64
+	if m == 0 || k == 0 || uint64(len(buf)-bloomHeaderSize) != words*8 {
+		return nil, ErrInvalidBloomFilter
+	}
+	if crc32.ChecksumIEEE(buf[bloomHeaderSize:]) != crc {
+		return nil, ErrInvalidCrc
+	}
+
+	b := &BloomFilter{bits: make([]uint64, words), m: m, k: k}
+	for i := range b.bits {
+		b.bits[i] = binary.BigEndian.Uint64(buf[bloomHeaderSize+i*8:])
+	}
+	return b, nil
+}
+
+// BloomFilePath 返回数据文件对应的布隆过滤器文件路径
+func BloomFilePath(path string, fileId uint32, eType uint16) string {
+	return path + PathSepatator + fmt.Sprintf(BloomFileFormatName, fileId, DBFileSuffixName[eType])
+}
+
+// BuildBloomFilter 扫描数据文件中的所有 entry，将 keysOf 返回的 key 加入布隆过滤器
+func (df *DBFile) BuildBloomFilter(fpRate float64, keysOf EntryKeys) (*BloomFilter, error) {
+	var hashes []uint64
+	scanner := df.NewScanner()
+	for {
+		e, _, err := scanner.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		for _, key := range keysOf(e) {
+			hashes = append(hashes, bloomHash(key))
+		}
+	}
+
+	b := NewBloomFilter(len(hashes), fpRate)
+	for _, h := range hashes {
+		b.addHash(h)
+	}
+	return b, nil
+}
+
+// Seal 封存数据文件时调用，构建布隆过滤器并持久化到数据文件旁
+func (df *DBFile) Seal(dirPath string, eType uint16, keysOf EntryKeys) error {
+	b, err := df.BuildBloomFilter(DefaultBloomFalsePositive, keysOf)
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(BloomFilePath(dirPath, df.Id, eType), b.Encode(), FilePerm); err != nil {
+		return err
+	}
+
+	df.Bloom = b
+	return nil
+}
+
+// MayContain 判断 key 是否可能存在于数据文件中，没有布隆过滤器时总是返回 true
+func (df *DBFile) MayContain(key []byte) bool {
+	if df.Bloom == nil {
+		return true
+	}
+	return df.Bloom.MayContain(key)
+}
+
+// LoadBloomFilter 加载已封存数据文件的布隆过滤器，文件不存在或已损坏时重新构建
+func (df *DBFile) LoadBloomFilter(dirPath string, eType uint16, keysOf EntryKeys) error {
+	if buf, err := utils.ReadFileChecked(BloomFilePath(dirPath, df.Id, eType)); err == nil {
+		if b, err := DecodeBloomFilter(buf); err == nil {
+			df.Bloom = b
+			return nil
+		}
+	}
+
+	return df.Seal(dirPath, eType, keysOf)
+}
+
+func (b *BloomFilter) addHash(h uint64) {
+	h1, h2 := splitHash(h)
+	for i := uint32(0); i < b.k; i++ {
+		pos := (h1 + uint64(i)*h2) % b.m
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+func bloomHash(key []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+	return h.Sum64()
+}
+
+// 使用两个哈希值模拟 k 个哈希函数：h1 + i*h2
+func splitHash(h uint64) (uint64, uint64) {
+	return h & 0xffffffff, h>>32 | 1
+}
@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/edsrzf/mmap-go"
-	"hash/crc32"
+	"io"
 	"io/ioutil"
 	"os"
 	"sort"
@@ -28,9 +28,11 @@ var (
 		2: "%09d.data.hash",
 		3: "%09d.data.set",
 		4: "%09d.data.zset",
+		5: "%09d.data.stream",
+		6: "%09d.data.json",
 	}
 
-	DBFileSuffixName = []string{"str", "list", "hash", "set", "zset"}
+	DBFileSuffixName = []string{"str", "list", "hash", "set", "zset", "stream", "json"}
 )
 
 type FileRWMethod uint8
@@ -47,6 +49,7 @@ type DBFile struct {
 	mmap   mmap.MMap
 	Offset int64
 	method FileRWMethod
+	Bloom  *BloomFilter // 已封存文件的布隆过滤器，活跃文件为 nil
 }
 
 func NewDBFile(path string, fileId uint32, method FileRWMethod, blockSize int64, eType uint16) (*DBFile, error) {
@@ -73,6 +76,7 @@ func NewDBFile(path string, fileId uint32, method FileRWMethod, blockSize int64,
 
 }
 
+// Read 读取 offset 处的 entry，先读取 entry 头部得到各部分的长度，再一次性读取 key、value 和 extra
 func (df *DBFile) Read(offset int64) (e *Entry, err error) {
 	var buf []byte
 	if buf, err = df.readBuf(offset, int64(entryHeaderSize)); err != nil {
@@ -81,39 +85,43 @@ func (df *DBFile) Read(offset int64) (e *Entry, err error) {
 	if e, err = Decode(buf); err != nil {
 		return nil, err
 	}
-	offset += entryHeaderSize
-	if e.Meta.KeySize > 0 {
-		var key []byte
-		if key, err = df.readBuf(offset, int64(e.Meta.KeySize)); err != nil {
+
+	bodySize := int64(e.Meta.KeySize) + int64(e.Meta.ValueSize) + int64(e.Meta.ExtraSize)
+	if bodySize > 0 {
+		if buf, err = df.readBuf(offset+entryHeaderSize, bodySize); err != nil {
 			return nil, err
 		}
-		e.Meta.Key = key
+		e.fillBody(buf)
 	}
 
-	offset += int64(e.Meta.KeySize) // 更新offset
-	if e.Meta.ValueSize > 0 {       // 如果解码出的entry中有value，就对其key进行赋值
-		var val []byte
-		if val, err = df.readBuf(offset, int64(e.Meta.ValueSize)); err != nil {
-			return
-		}
-		e.Meta.Value = val
+	if err = e.checkCrc(); err != nil {
+		return nil, err
 	}
+	return
+}
 
-	offset += int64(e.Meta.ValueSize) // 更新offset
-	if e.Meta.ExtraSize > 0 {         // 如果解码出的entry中有extra，就对其key进行赋值
-		var val []byte
-		if val, err = df.readBuf(offset, int64(e.Meta.ExtraSize)); err != nil {
-			return
-		}
-		e.Meta.Extra = val
+// ReadEntry 在已知 entry 大小（例如索引中记录的 EntrySize）时，只需一次读取即可得到完整的 entry
+func (df *DBFile) ReadEntry(offset int64, size uint32) (e *Entry, err error) {
+	if size < entryHeaderSize {
+		return nil, ErrInvalidEntry
 	}
 
-	checkCrc := crc32.ChecksumIEEE(e.Meta.Value)
-	if checkCrc != e.crc32 {
-		return nil, ErrInvalidCrc
+	var buf []byte
+	if buf, err = df.readBuf(offset, int64(size)); err != nil {
+		return nil, err
+	}
+	if e, err = Decode(buf); err != nil {
+		return nil, err
+	}
+	if e.Size() != size {
+		return nil, ErrInvalidEntry
 	}
-	return
 
+	e.fillBody(buf[entryHeaderSize:])
+	if err = e.checkCrc(); err != nil {
+		return nil, err
+	}
+	return
 }
 
 func (df *DBFile) readBuf(offset int64, n int64) ([]byte, error) {
@@ -124,8 +132,11 @@ func (df *DBFile) readBuf(offset int64, n int64) ([]byte, error) {
 			return nil, err
 		}
 	}
-	if df.method == MMap && offset <= int64(len(df.mmap)) {
-		copy(buf, df.mmap)
+	if df.method == MMap {
+		if offset+n > int64(len(df.mmap)) {
+			return nil, io.EOF
+		}
+		copy(buf, df.mmap[offset:])
 	}
 	return buf, nil
 }
@@ -177,6 +188,20 @@ func (df *DBFile) Sync() (err error) {
 	return
 }
 
+// Remove 关闭并删除数据文件，以及其对应的布隆过滤器文件
+func (df *DBFile) Remove(dirPath string, eType uint16) error {
+	if err := df.Close(false); err != nil {
+		return err
+	}
+	if err := os.Remove(df.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(BloomFilePath(dirPath, df.Id, eType)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func Build(path string, method FileRWMethod, blockSize int64) (map[uint16]map[uint32]*DBFile, map[uint16]uint32, error) {
 	dir, err := ioutil.ReadDir(path)
 	if err != nil {
@@ -188,18 +213,10 @@ func Build(path string, method FileRWMethod, blockSize int64) (map[uint16]map[ui
 		if strings.Contains(d.Name(), "data") {
 			splitnames := strings.Split(d.Name(), ".")
 			id, _ := strconv.Atoi(splitnames[0])
-			switch splitnames[2] {
-			case DBFileSuffixName[0]:
-				fileIdsMap[0] = append(fileIdsMap[0], id)
-			case DBFileSuffixName[1]:
-				fileIdsMap[1] = append(fileIdsMap[1], id)
-			case DBFileSuffixName[2]:
-				fileIdsMap[2] = append(fileIdsMap[2], id)
-			case DBFileSuffixName[3]:
-				fileIdsMap[3] = append(fileIdsMap[3], id)
-			case DBFileSuffixName[4]:
-				fileIdsMap[4] = append(fileIdsMap[4], id)
-
+			for dataType, suffix := range DBFileSuffixName {
+				if splitnames[2] == suffix {
+					fileIdsMap[uint16(dataType)] = append(fileIdsMap[uint16(dataType)], id)
+				}
 			}
 		}
 	}
@@ -207,7 +224,7 @@ func Build(path string, method FileRWMethod, blockSize int64) (map[uint16]map[ui
 	activeFileIds := make(map[uint16]uint32)
 	archFiles := make(map[uint16]map[uint32]*DBFile)
 	var dataType uint16 = 0
-	for ; dataType < 5; dataType++ {
+	for ; int(dataType) < len(DBFileSuffixName); dataType++ {
 		fileIds := fileIdsMap[dataType]
 		sort.Ints(fileIds)
 		files := make(map[uint32]*DBFile)
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+const benchEntryNum = 10000
+
+// 新建一个写入了 benchEntryNum 条 entry 的数据文件，返回文件及每条 entry 的偏移和大小
+func newBenchFile(b *testing.B, method FileRWMethod) (*DBFile, []int64, []uint32) {
+	b.Helper()
+
+	df, err := NewDBFile(b.TempDir(), 0, method, 64*1024*1024, String)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = df.Close(false) })
+
+	offsets := make([]int64, 0, benchEntryNum)
+	sizes := make([]uint32, 0, benchEntryNum)
+	value := make([]byte, 128)
+	for i := 0; i < benchEntryNum; i++ {
+		e := NewEntryNoExtra([]byte(fmt.Sprintf("key-%08d", i)), value, String, 0)
+		offsets = append(offsets, df.Offset)
+		sizes = append(sizes, e.Size())
+		if err := df.Write(e); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return df, offsets, sizes
+}
+
+// BenchmarkRead 比较按偏移随机读取单条 entry 时，先读头部再读其余部分的 Read 与按已知大小一次读取的 ReadEntry
+func BenchmarkRead(b *testing.B) {
+	for _, method := range []FileRWMethod{FileIO, MMap} {
+		df, offsets, sizes := newBenchFile(b, method)
+		name := map[FileRWMethod]string{FileIO: "FileIO", MMap: "MMap"}[method]
+
+		b.Run(name+"/Read", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := df.Read(offsets[i%benchEntryNum]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/ReadEntry", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				n := i % benchEntryNum
+				if _, err := df.ReadEntry(offsets[n], sizes[n]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLoadIdx 比较启动时加载索引的全量扫描中，逐条按偏移调用 Read 与使用带缓冲的 Scanner 顺序读取
+func BenchmarkLoadIdx(b *testing.B) {
+	df, _, _ := newBenchFile(b, FileIO)
+
+	b.Run("Read", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var offset int64
+			for n := 0; n < benchEntryNum; n++ {
+				e, err := df.Read(offset)
+				if err != nil {
+					b.Fatal(err)
+				}
+				offset += int64(e.Size())
+			}
+		}
+	})
+	b.Run("Scanner", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			scanner := df.NewScanner()
+			n := 0
+			for {
+				if _, _, err := scanner.Next(); err == io.EOF {
+					break
+				} else if err != nil {
+					b.Fatal(err)
+				}
+				n++
+			}
+			if n != benchEntryNum {
+				b.Fatalf("scanned %d entries, want %d", n, benchEntryNum)
+			}
+		}
+	})
+}
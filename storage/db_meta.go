@@ -1,9 +1,8 @@
 package storage
 
 import (
+	"KV_Storage/utils"
 	"encoding/json"
-	"io/ioutil"
-	"os"
 )
 
 // DBMeta 保存数据库的一些额外信息
@@ -15,14 +14,7 @@ type DBMeta struct {
 func LoadMeta(path string) (m *DBMeta, err error) {
 	m = &DBMeta{ActiveWriteOff: make(map[uint16]int64)}
 
-	file, err := os.OpenFile(path, os.O_RDONLY, 0600) // 只读权限打开path路径下的文件
-	if err != nil {
-		return
-	}
-
-	defer file.Close()
-
-	b, err := ioutil.ReadAll(file) // 读取文件
+	b, err := utils.ReadFileChecked(path) // 读取文件并校验
 	if err != nil {
 		return
 	}
@@ -36,18 +28,10 @@ func LoadMeta(path string) (m *DBMeta, err error) {
 
 // Store 将数据库信息存储
 func (m *DBMeta) Store(path string) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		return err
-	}
-
-	defer file.Close()
-
 	b, err := json.Marshal(m) // 对DBMeta进行json编码
 	if err != nil {
 		return err
 	}
 
-	_, err = file.Write(b) // 写入到文件中
-	return err
+	return utils.WriteFileAtomic(path, b, 0600) // 原子地写入到文件中
 }
@@ -25,6 +25,8 @@ const (
 	Hash
 	Set
 	ZSet
+	Stream
+	JSON
 )
 
 type (
@@ -93,7 +95,12 @@ func (e *Entry) Encode() ([]byte, error) {
 	return buf, nil
 }
 
+// Decode 解码 entry 的头部信息
 func Decode(buf []byte) (*Entry, error) {
+	if len(buf) < entryHeaderSize {
+		return nil, ErrInvalidEntry
+	}
+
 	ks := binary.BigEndian.Uint32(buf[4:8])
 	vs := binary.BigEndian.Uint32(buf[8:12])
 	es := binary.BigEndian.Uint32(buf[12:16])
@@ -112,3 +119,24 @@ func Decode(buf []byte) (*Entry, error) {
 		crc32: crc,
 	}, nil
 }
+
+// 根据头部中的长度信息，从 buf 中切分出 key、value 和 extra
+func (e *Entry) fillBody(buf []byte) {
+	ks, vs, es := e.Meta.KeySize, e.Meta.ValueSize, e.Meta.ExtraSize
+	if ks > 0 {
+		e.Meta.Key = buf[:ks:ks]
+	}
+	if vs > 0 {
+		e.Meta.Value = buf[ks : ks+vs : ks+vs]
+	}
+	if es > 0 {
+		e.Meta.Extra = buf[ks+vs : ks+vs+es : ks+vs+es]
+	}
+}
+
+func (e *Entry) checkCrc() error {
+	if crc32.ChecksumIEEE(e.Meta.Value) != e.crc32 {
+		return ErrInvalidCrc
+	}
+	return nil
+}
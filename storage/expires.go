@@ -1,15 +1,29 @@
 package storage
 
 import (
+	"KV_Storage/utils"
 	"encoding/binary"
 	"io"
 	"log"
 	"os"
 )
 
-const expireHeadSize = 12
+const (
+	expireHeadSize = 12
 
-type Expires map[string]uint32
+	// 过期字典文件的头部：magic(4) + version(4)
+	// 旧版本的文件没有头部，记录以 key 的长度开头，而 key 的长度不会大到与 magic 相同，据此可以区分新旧两种格式
+	expireFileHeadSize = 8
+	expireFileMagic    = 0x4B564558 // "KVEX"
+
+	// 旧版本中 deadline 为秒级时间戳
+	expireVersionSeconds = 1
+	// 当前版本中 deadline 为毫秒级时间戳
+	expireVersionMillis = 2
+)
+
+// Expires 过期字典，value 为毫秒级的 Unix 时间戳
+type Expires map[string]uint64
 
 type ExpireValue struct {
 	Key      []byte
@@ -17,80 +31,75 @@ type ExpireValue struct {
 	Deadline uint64
 }
 
+// SaveExpires 将过期字典原子地保存到文件中
 func (e *Expires) SaveExpires(path string) (err error) {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
-	if err != nil {
-		return err
+	size := expireFileHeadSize
+	for k := range *e {
+		size += expireHeadSize + len(k)
 	}
-	defer file.Close()
-
-	var offset int64 = 0
-	for k, v := range *e {
-		ev := &ExpireValue{
-			Key:      []byte(k),
-			KeySize:  uint32(len([]byte(k))),
-			Deadline: uint64(v),
-		}
 
-		buf := make([]byte, ev.KeySize+expireHeadSize)
-		binary.BigEndian.PutUint32(buf[0:4], ev.KeySize)
-		binary.BigEndian.PutUint64(buf[4:12], ev.Deadline)
-		copy(buf[expireHeadSize:], ev.Key)
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf[0:4], expireFileMagic)
+	binary.BigEndian.PutUint32(buf[4:8], expireVersionMillis)
 
-		_, err = file.WriteAt(buf, offset)
-		if err != nil {
-			return err
-		}
-		offset += int64(expireHeadSize + ev.KeySize)
+	offset := expireFileHeadSize
+	for k, v := range *e {
+		binary.BigEndian.PutUint32(buf[offset:offset+4], uint32(len(k)))
+		binary.BigEndian.PutUint64(buf[offset+4:offset+12], v)
+		copy(buf[offset+expireHeadSize:], k)
+		offset += expireHeadSize + len(k)
 	}
-	return
+
+	return utils.WriteFileAtomic(path, buf, 0600)
 }
 
+// LoadExpires 加载过期字典，文件不存在或已损坏时返回空的过期字典
+// 过期时间的变更都会记录在数据文件中，恢复索引时会重新构建，这里的文件只是一个可选的检查点
 func LoadExpires(path string) (expires Expires) {
 	expires = make(Expires)
-	file, err := os.OpenFile(path, os.O_RDONLY, 0600)
+	buf, err := utils.ReadFileChecked(path)
 	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("load expire err: ", err)
+		}
 		return
 	}
-	defer file.Close()
 
-	var offset int64 = 0
-	for {
-		ev, err := readExpire(file, offset)
+	offset := 0
+	version := uint32(expireVersionSeconds)
+	if len(buf) >= expireFileHeadSize && binary.BigEndian.Uint32(buf[0:4]) == expireFileMagic {
+		version = binary.BigEndian.Uint32(buf[4:8])
+		offset = expireFileHeadSize
+	}
+
+	for offset < len(buf) {
+		ev, err := decodeExpire(buf[offset:])
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
 			log.Println("load expire err: ", err)
 			return
 		}
-		offset += int64(ev.KeySize + expireHeadSize)
-		expires[string(ev.Key)] = uint32(ev.Deadline)
+		offset += int(ev.KeySize) + expireHeadSize
+
+		// 旧版本的秒级时间戳转换为毫秒级
+		if version == expireVersionSeconds {
+			ev.Deadline *= 1000
+		}
+		expires[string(ev.Key)] = ev.Deadline
 	}
 	return
 }
 
-func readExpire(file *os.File, offset int64) (ev *ExpireValue, err error) {
-	buf := make([]byte, expireHeadSize)
-	_, err = file.ReadAt(buf, offset)
-	if err != nil {
-		return
+func decodeExpire(buf []byte) (*ExpireValue, error) {
+	if len(buf) < expireHeadSize {
+		return nil, io.ErrUnexpectedEOF
 	}
-	ev = decodeExpire(buf)
-	offset += expireHeadSize
-	key := make([]byte, ev.KeySize)
-	_, err = file.ReadAt(key, offset)
-	if err != nil {
-		return
-	}
-	ev.Key = key
-	return
 
-}
-
-func decodeExpire(buf []byte) *ExpireValue {
 	ev := &ExpireValue{}
 	ev.KeySize = binary.BigEndian.Uint32(buf[0:4])
 	ev.Deadline = binary.BigEndian.Uint64(buf[4:12])
-	return ev
+	if uint64(len(buf)-expireHeadSize) < uint64(ev.KeySize) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	ev.Key = buf[expireHeadSize : expireHeadSize+int(ev.KeySize)]
+	return ev, nil
 }
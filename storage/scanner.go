@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+)
+
+// 顺序扫描数据文件时使用的默认缓冲区大小
+const defaultScanBufSize = 256 * 1024
+
+// Scanner 从头到尾顺序读取数据文件中的 entry
+// 与按 offset 随机读取相比，带缓冲的顺序读取可以大幅减少系统调用次数，适用于启动时加载索引等全量扫描的场景
+type Scanner struct {
+	r      *bufio.Reader
+	offset int64
+	header []byte
+}
+
+// NewScanner 新建一个从文件起始位置开始扫描的 Scanner
+func (df *DBFile) NewScanner() *Scanner {
+	var src io.Reader
+	if df.method == MMap {
+		src = bytes.NewReader(df.mmap)
+	} else {
+		src = io.NewSectionReader(df.File, 0, math.MaxInt64)
+	}
+
+	return &Scanner{
+		r:      bufio.NewReaderSize(src, defaultScanBufSize),
+		header: make([]byte, entryHeaderSize),
+	}
+}
+
+// Next 返回下一条 entry 及其在文件中的偏移，读取到文件末尾时返回 io.EOF
+func (s *Scanner) Next() (e *Entry, offset int64, err error) {
+	if _, err = io.ReadFull(s.r, s.header); err != nil {
+		return nil, 0, eofErr(err)
+	}
+	if e, err = Decode(s.header); err != nil {
+		return nil, 0, err
+	}
+
+	// 写入的 entry 的 key 不能为空，读取到全零的头部说明已经没有有效数据了（MMap 模式下文件尾部会被填充为0）
+	if e.Meta.KeySize == 0 {
+		return nil, 0, io.EOF
+	}
+
+	bodySize := int64(e.Meta.KeySize) + int64(e.Meta.ValueSize) + int64(e.Meta.ExtraSize)
+	buf := make([]byte, bodySize)
+	if _, err = io.ReadFull(s.r, buf); err != nil {
+		return nil, 0, eofErr(err)
+	}
+	e.fillBody(buf)
+
+	if err = e.checkCrc(); err != nil {
+		return nil, 0, err
+	}
+
+	offset = s.offset
+	s.offset += int64(e.Size())
+	return
+}
+
+// Offset 返回下一条 entry 的起始偏移
+func (s *Scanner) Offset() int64 {
+	return s.offset
+}
+
+// 文件末尾不完整的 entry 与随机读取时的处理保持一致，视为读取结束
+func eofErr(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var ErrInvalidEncodedValues = errors.New("utils: invalid encoded values")
+
+// EncodeValues 将多个字节串编码为一个，每个字节串之前是其长度的变长编码，用于在一条日志中保存多个值
+func EncodeValues(values [][]byte) []byte {
+	var buf []byte
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, v := range values {
+		n := binary.PutUvarint(lenBuf[:], uint64(len(v)))
+		buf = append(buf, lenBuf[:n]...)
+		buf = append(buf, v...)
+	}
+	return buf
+}
+
+// EncodedSize 返回 EncodeValues 编码 values 后的长度
+func EncodedSize(values [][]byte) (size int) {
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, v := range values {
+		size += binary.PutUvarint(lenBuf[:], uint64(len(v))) + len(v)
+	}
+	return
+}
+
+// DecodeValues EncodeValues 的逆操作
+func DecodeValues(buf []byte) (values [][]byte, err error) {
+	for len(buf) > 0 {
+		size, n := binary.Uvarint(buf)
+		if n <= 0 || uint64(len(buf)-n) < size {
+			return nil, ErrInvalidEncodedValues
+		}
+		buf = buf[n:]
+		values = append(values, buf[:size:size])
+		buf = buf[size:]
+	}
+	return
+}
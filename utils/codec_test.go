@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestValuesRoundTrip(t *testing.T) {
+	cases := [][][]byte{
+		nil,
+		{nil},
+		{{}, []byte("a"), nil},
+		{[]byte("field"), []byte("value"), []byte(strings.Repeat("x", 300))},
+	}
+	for _, values := range cases {
+		got, err := DecodeValues(EncodeValues(values))
+		if err != nil {
+			t.Fatalf("DecodeValues(%q): %v", values, err)
+		}
+		if len(got) != len(values) {
+			t.Fatalf("DecodeValues = %q, want %q", got, values)
+		}
+		for i := range values {
+			if !bytes.Equal(got[i], values[i]) {
+				t.Fatalf("DecodeValues = %q, want %q", got, values)
+			}
+		}
+	}
+}
+
+func TestDecodedValuesDoNotAlias(t *testing.T) {
+	got, err := DecodeValues(EncodeValues([][]byte{[]byte("ab"), []byte("cd")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 解码出的值的容量被截断，追加时不能覆盖后面的值
+	_ = append(got[0], 'x')
+	if string(got[1]) != "cd" {
+		t.Fatalf("appending to the first value changed the second one to %q", got[1])
+	}
+}
+
+func TestDecodeInvalidValues(t *testing.T) {
+	valid := EncodeValues([][]byte{[]byte("hello"), []byte(strings.Repeat("y", 200))})
+	cases := map[string][]byte{
+		"truncated value":  valid[:len(valid)-1],
+		"truncated length": {0x80},
+		"length overflow":  {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"length too large": {0x05, 'a', 'b'},
+	}
+	for name, buf := range cases {
+		if _, err := DecodeValues(buf); err != ErrInvalidEncodedValues {
+			t.Errorf("%s: err = %v, want ErrInvalidEncodedValues", name, err)
+		}
+	}
+}
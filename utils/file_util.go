@@ -1,10 +1,22 @@
 package utils
 
 import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+)
+
+var ErrChecksumMismatch = errors.New("utils: file checksum mismatch")
+
+const (
+	// 文件末尾的校验信息：crc32(4) + magic(4)
+	checksumFooterSize = 8
+	checksumMagic      = 0x4B56434B // "KVCK"
 )
 
 func Exist(path string) bool {
@@ -73,3 +85,69 @@ func CopyFile(src, dst string) error {
 
 	return os.Chmod(dst, srcInfo.Mode())
 }
+
+// WriteFileAtomic 原子地写入文件，并在文件末尾附加 crc32 校验和
+// 先写入同目录下的临时文件并同步到磁盘，再重命名为目标文件，最后同步目录
+// 这样即使写入过程中发生崩溃，目标文件也只会是旧的完整内容或新的完整内容
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) (err error) {
+	tmp := filename + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	footer := make([]byte, checksumFooterSize)
+	binary.BigEndian.PutUint32(footer[0:4], crc32.ChecksumIEEE(data))
+	binary.BigEndian.PutUint32(footer[4:8], checksumMagic)
+
+	if _, err = file.Write(data); err == nil {
+		_, err = file.Write(footer)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, filename); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(filename))
+}
+
+// ReadFileChecked 读取由 WriteFileAtomic 写入的文件并校验，返回去掉校验信息后的内容
+// 没有校验信息的旧版本文件会原样返回
+func ReadFileChecked(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(data) - checksumFooterSize
+	if n < 0 || binary.BigEndian.Uint32(data[n+4:]) != checksumMagic {
+		return data, nil
+	}
+	if crc32.ChecksumIEEE(data[:n]) != binary.BigEndian.Uint32(data[n:n+4]) {
+		return nil, ErrChecksumMismatch
+	}
+	return data[:n], nil
+}
+
+// 同步目录，保证目录中的重命名等操作已经持久化
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
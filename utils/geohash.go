@@ -0,0 +1,215 @@
+package utils
+
+import "math"
+
+// 与 Redis 相同的 geohash 实现，经纬度各 26 位交错编码成 52 位的整数，可以无损地保存为有序集合的 score
+
+const (
+	GeoLonMin = -180.0
+	GeoLonMax = 180.0
+	GeoLatMin = -85.05112878 // EPSG:900913 / EPSG:3785 / OSGEO:41001 规定的纬度范围
+	GeoLatMax = 85.05112878
+
+	GeoStepMax = 26 // 经纬度各自编码的最大位数
+
+	earthRadiusInMeters = 6372797.560856
+	mercatorMax         = 20037726.37
+)
+
+// GeoHash 指定精度的 geohash 编码，即一个经纬度网格
+type GeoHash struct {
+	Bits uint64
+	Step uint
+}
+
+// GeoArea geohash 网格覆盖的经纬度范围
+type GeoArea struct {
+	LonMin, LonMax float64
+	LatMin, LatMax float64
+}
+
+// GeoHashEncode 将经纬度编码为指定精度的 geohash，经纬度超出范围时 ok 为 false
+func GeoHashEncode(lon, lat float64, step uint) (hash GeoHash, ok bool) {
+	if lon < GeoLonMin || lon > GeoLonMax || lat < GeoLatMin || lat > GeoLatMax {
+		return
+	}
+
+	latOffset := (lat - GeoLatMin) / (GeoLatMax - GeoLatMin) * float64(uint64(1)<<step)
+	lonOffset := (lon - GeoLonMin) / (GeoLonMax - GeoLonMin) * float64(uint64(1)<<step)
+	return GeoHash{Bits: interleave64(uint32(latOffset), uint32(lonOffset)), Step: step}, true
+}
+
+// Area 返回 geohash 网格覆盖的经纬度范围
+func (h GeoHash) Area() GeoArea {
+	ilat, ilon := deinterleave64(h.Bits)
+	cells := float64(uint64(1) << h.Step)
+	latScale, lonScale := GeoLatMax-GeoLatMin, GeoLonMax-GeoLonMin
+	return GeoArea{
+		LatMin: GeoLatMin + float64(ilat)/cells*latScale,
+		LatMax: GeoLatMin + float64(ilat+1)/cells*latScale,
+		LonMin: GeoLonMin + float64(ilon)/cells*lonScale,
+		LonMax: GeoLonMin + float64(ilon+1)/cells*lonScale,
+	}
+}
+
+// Center 返回 geohash 网格的中心点
+func (h GeoHash) Center() (lon, lat float64) {
+	area := h.Area()
+	lon = math.Max(GeoLonMin, math.Min(GeoLonMax, (area.LonMin+area.LonMax)/2))
+	lat = math.Max(GeoLatMin, math.Min(GeoLatMax, (area.LatMin+area.LatMax)/2))
+	return
+}
+
+// ScoreRange 返回该网格内的点以最大精度编码后的取值范围 [min, max)
+func (h GeoHash) ScoreRange() (min, max uint64) {
+	shift := 2 * (GeoStepMax - h.Step)
+	return h.Bits << shift, (h.Bits + 1) << shift
+}
+
+// Neighbors 返回当前网格及其周围的 8 个网格，经度方向上首尾相接
+func (h GeoHash) Neighbors() []GeoHash {
+	var res []GeoHash
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			res = append(res, h.moveY(dy).moveX(dx))
+		}
+	}
+	return res
+}
+
+// 沿经度方向移动一格，经度占据奇数位
+func (h GeoHash) moveX(d int) GeoHash {
+	if d == 0 {
+		return h
+	}
+	x := h.Bits & 0xaaaaaaaaaaaaaaaa
+	y := h.Bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - h.Step*2)
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - h.Step*2)
+	return GeoHash{Bits: x | y, Step: h.Step}
+}
+
+// 沿纬度方向移动一格，纬度占据偶数位
+func (h GeoHash) moveY(d int) GeoHash {
+	if d == 0 {
+		return h
+	}
+	x := h.Bits & 0xaaaaaaaaaaaaaaaa
+	y := h.Bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - h.Step*2)
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= 0x5555555555555555 >> (64 - h.Step*2)
+	return GeoHash{Bits: x | y, Step: h.Step}
+}
+
+// GeoHashDecodeScore 将有序集合中保存的 score 解码为经纬度，结果为所在网格的中心点
+func GeoHashDecodeScore(score float64) (lon, lat float64) {
+	return GeoHash{Bits: uint64(score), Step: GeoStepMax}.Center()
+}
+
+// GeoEstimateSteps 估算能以 3x3 个网格覆盖半径为 radius（单位为米）的圆的最大精度
+func GeoEstimateSteps(radius, lat float64) uint {
+	if radius == 0 {
+		return GeoStepMax
+	}
+
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2 // 保证大部分情况下能够覆盖
+
+	// 高纬度地区的网格在经度方向上更窄
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+
+	if step < 1 {
+		step = 1
+	}
+	if step > GeoStepMax {
+		step = GeoStepMax
+	}
+	return uint(step)
+}
+
+// GeoBoundingBox 返回以 (lon, lat) 为中心，宽 width 高 height（单位为米）的矩形的经纬度范围
+func GeoBoundingBox(lon, lat, width, height float64) GeoArea {
+	latDelta := radToDeg(height / 2 / earthRadiusInMeters)
+	lonDeltaTop := radToDeg(width / 2 / earthRadiusInMeters / math.Cos(degToRad(lat+latDelta)))
+	lonDeltaBottom := radToDeg(width / 2 / earthRadiusInMeters / math.Cos(degToRad(lat-latDelta)))
+	lonDelta := math.Max(math.Abs(lonDeltaTop), math.Abs(lonDeltaBottom))
+	return GeoArea{
+		LonMin: lon - lonDelta,
+		LonMax: lon + lonDelta,
+		LatMin: lat - latDelta,
+		LatMax: lat + latDelta,
+	}
+}
+
+// GeoDistance 使用 haversine 公式计算两点之间的距离，单位为米
+func GeoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lon1r := degToRad(lat1), degToRad(lon1)
+	lat2r, lon2r := degToRad(lat2), degToRad(lon2)
+	v := math.Sin((lon2r - lon1r) / 2)
+	if v == 0 {
+		return GeoLatDistance(lat1, lat2)
+	}
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * earthRadiusInMeters * math.Asin(math.Sqrt(a))
+}
+
+// GeoLatDistance 返回两个纬度之间沿经线的距离，单位为米
+func GeoLatDistance(lat1, lat2 float64) float64 {
+	return earthRadiusInMeters * math.Abs(degToRad(lat2)-degToRad(lat1))
+}
+
+func degToRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radToDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// 将 x 和 y 的位交错排列，x 占据偶数位，y 占据奇数位
+func interleave64(xlo, ylo uint32) uint64 {
+	B := [...]uint64{0x5555555555555555, 0x3333333333333333, 0x0F0F0F0F0F0F0F0F, 0x00FF00FF00FF00FF, 0x0000FFFF0000FFFF}
+	S := [...]uint{1, 2, 4, 8, 16}
+
+	x, y := uint64(xlo), uint64(ylo)
+	for i := len(S) - 1; i >= 0; i-- {
+		x = (x | (x << S[i])) & B[i]
+		y = (y | (y << S[i])) & B[i]
+	}
+	return x | (y << 1)
+}
+
+// interleave64 的逆运算
+func deinterleave64(interleaved uint64) (x, y uint32) {
+	B := [...]uint64{0x5555555555555555, 0x3333333333333333, 0x0F0F0F0F0F0F0F0F, 0x00FF00FF00FF00FF, 0x0000FFFF0000FFFF, 0x00000000FFFFFFFF}
+	S := [...]uint{0, 1, 2, 4, 8, 16}
+
+	xx, yy := interleaved, interleaved>>1
+	for i := range S {
+		xx = (xx | (xx >> S[i])) & B[i]
+		yy = (yy | (yy >> S[i])) & B[i]
+	}
+	return uint32(xx), uint32(yy)
+}
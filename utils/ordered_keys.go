@@ -0,0 +1,118 @@
+package utils
+
+import "math/rand"
+
+const (
+	orderedKeysMaxLevel    = 32
+	orderedKeysProbability = 0.25
+)
+
+// OrderedKeys 按字典序保存一组互不相同的字符串的跳表，与 map 配合使用作为其有序的二级索引
+// 基于游标的迭代可以从游标处直接继续，每次迭代的时间复杂度为 O(log n + count)，而不需要遍历整个 map
+type OrderedKeys struct {
+	head   *orderedKeysNode
+	level  int
+	length int
+}
+
+type orderedKeysNode struct {
+	key  string
+	next []*orderedKeysNode
+}
+
+// NewOrderedKeys 新建一个空的 OrderedKeys
+func NewOrderedKeys() *OrderedKeys {
+	return &OrderedKeys{
+		head:  &orderedKeysNode{next: make([]*orderedKeysNode, orderedKeysMaxLevel)},
+		level: 1,
+	}
+}
+
+// Len 返回字符串的个数
+func (o *OrderedKeys) Len() int {
+	return o.length
+}
+
+// Add 加入字符串 key，返回其之前是否不存在
+func (o *OrderedKeys) Add(key string) bool {
+	var prev [orderedKeysMaxLevel]*orderedKeysNode
+	o.backNodes(key, &prev)
+	if next := prev[0].next[0]; next != nil && next.key == key {
+		return false
+	}
+
+	level := orderedKeysRandomLevel()
+	if level > o.level {
+		for i := o.level; i < level; i++ {
+			prev[i] = o.head
+		}
+		o.level = level
+	}
+
+	node := &orderedKeysNode{key: key, next: make([]*orderedKeysNode, level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = prev[i].next[i]
+		prev[i].next[i] = node
+	}
+	o.length++
+	return true
+}
+
+// Remove 删除字符串 key，返回其之前是否存在
+func (o *OrderedKeys) Remove(key string) bool {
+	var prev [orderedKeysMaxLevel]*orderedKeysNode
+	o.backNodes(key, &prev)
+	node := prev[0].next[0]
+	if node == nil || node.key != key {
+		return false
+	}
+
+	for i, next := range node.next {
+		prev[i].next[i] = next
+	}
+	for o.level > 1 && o.head.next[o.level-1] == nil {
+		o.level--
+	}
+	o.length--
+	return true
+}
+
+// Ascend 按字典序依次将字符串交给 fn，直到 fn 返回 false
+// bounded 为 false 时从第一个字符串开始，否则从第一个大于 after 的字符串开始
+func (o *OrderedKeys) Ascend(after string, bounded bool, fn func(key string) bool) {
+	node := o.head.next[0]
+	if bounded {
+		x := o.head
+		for i := o.level - 1; i >= 0; i-- {
+			for x.next[i] != nil && x.next[i].key <= after {
+				x = x.next[i]
+			}
+		}
+		node = x.next[0]
+	}
+
+	for ; node != nil; node = node.next[0] {
+		if !fn(node.key) {
+			return
+		}
+	}
+}
+
+// 找到每一层中最后一个小于 key 的节点
+func (o *OrderedKeys) backNodes(key string, prev *[orderedKeysMaxLevel]*orderedKeysNode) {
+	x := o.head
+	for i := o.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].key < key {
+			x = x.next[i]
+		}
+		prev[i] = x
+	}
+}
+
+func orderedKeysRandomLevel() int {
+	level := 1
+	for level < orderedKeysMaxLevel && float32(rand.Int31()&0xFFFF) < (orderedKeysProbability*0xFFFF) {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func collect(o *OrderedKeys, after string, bounded bool, limit int) []string {
+	var res []string
+	o.Ascend(after, bounded, func(key string) bool {
+		res = append(res, key)
+		return len(res) < limit
+	})
+	return res
+}
+
+func assertKeys(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestOrderedKeys(t *testing.T) {
+	o := NewOrderedKeys()
+	for _, k := range []string{"c", "a", "", "b", "d"} {
+		if !o.Add(k) {
+			t.Fatalf("Add(%q) of a new key returned false", k)
+		}
+	}
+	if o.Add("b") {
+		t.Fatal("Add of an existing key returned true")
+	}
+	if o.Len() != 5 {
+		t.Fatalf("Len = %d, want 5", o.Len())
+	}
+
+	assertKeys(t, collect(o, "", false, 100), "", "a", "b", "c", "d")
+	// 有界时从第一个大于 after 的字符串开始，空字符串作为游标时跳过空字符串本身
+	assertKeys(t, collect(o, "", true, 100), "a", "b", "c", "d")
+	assertKeys(t, collect(o, "b", true, 100), "c", "d")
+	assertKeys(t, collect(o, "bb", true, 100), "c", "d")
+	assertKeys(t, collect(o, "z", true, 100))
+	assertKeys(t, collect(o, "a", true, 2), "b", "c")
+
+	if !o.Remove("c") || o.Remove("c") || o.Remove("missing") {
+		t.Fatal("Remove should report whether the key existed")
+	}
+	if !o.Remove("") {
+		t.Fatal("Remove of the empty key returned false")
+	}
+	if o.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", o.Len())
+	}
+	assertKeys(t, collect(o, "", false, 100), "a", "b", "d")
+	assertKeys(t, collect(o, "b", true, 100), "d")
+}
+
+func TestOrderedKeysRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	o := NewOrderedKeys()
+	present := make(map[string]bool)
+
+	for i := 0; i < 20000; i++ {
+		k := strconv.Itoa(r.Intn(2000))
+		if r.Intn(3) == 0 {
+			if o.Remove(k) != present[k] {
+				t.Fatalf("Remove(%q) disagrees with the map", k)
+			}
+			delete(present, k)
+		} else {
+			if o.Add(k) == present[k] {
+				t.Fatalf("Add(%q) disagrees with the map", k)
+			}
+			present[k] = true
+		}
+	}
+
+	want := make([]string, 0, len(present))
+	for k := range present {
+		want = append(want, k)
+	}
+	sort.Strings(want)
+	if o.Len() != len(want) {
+		t.Fatalf("Len = %d, want %d", o.Len(), len(want))
+	}
+	assertKeys(t, collect(o, "", false, len(want)+1), want...)
+
+	// 以每次迭代的最后一个字符串作为游标分页，结果与一次完整迭代相同
+	var paged []string
+	after, bounded := "", false
+	for {
+		page := collect(o, after, bounded, 37)
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page...)
+		after, bounded = page[len(page)-1], true
+	}
+	assertKeys(t, paged, want...)
+
+	for i := 0; i < 100; i++ {
+		after := strconv.Itoa(r.Intn(2200))
+		j := sort.SearchStrings(want, after)
+		if j < len(want) && want[j] == after {
+			j++
+		}
+		assertKeys(t, collect(o, after, true, len(want)+1), want[j:]...)
+	}
+}
@@ -9,3 +9,94 @@ func Float64ToStr(val float64) string {
 func StrToFloat64(val string) (float64, error) {
 	return strconv.ParseFloat(val, 64)
 }
+
+// GlobMatch 判断 str 是否匹配 glob 风格的 pattern，规则与 Redis 的 KEYS 命令一致
+// 支持 * （任意个字符）、? （单个字符）、[abc] [^a] [a-z] （字符集合）以及 \ 转义
+func GlobMatch(pattern, str []byte) bool {
+	p, s := 0, 0
+	starP, starS := -1, 0 // 最近一个 * 的位置，以及它当前匹配到的 str 的位置，用于回溯
+	for s < len(str) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starS = p, s
+				p++
+				continue
+			case '?':
+				p++
+				s++
+				continue
+			case '[':
+				if n, ok := matchClass(pattern[p:], str[s]); ok {
+					p += n
+					s++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) {
+					if pattern[p+1] == str[s] {
+						p += 2
+						s++
+						continue
+					}
+				} else if str[s] == '\\' {
+					p++
+					s++
+					continue
+				}
+			default:
+				if pattern[p] == str[s] {
+					p++
+					s++
+					continue
+				}
+			}
+		}
+
+		// 匹配失败时让最近的 * 多匹配一个字符后重试
+		if starP < 0 {
+			return false
+		}
+		starS++
+		p, s = starP+1, starS
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// 匹配以 [ 开头的字符集合，返回集合在 pattern 中的长度以及 c 是否在集合中
+func matchClass(pattern []byte, c byte) (n int, ok bool) {
+	i := 1
+	not := i < len(pattern) && pattern[i] == '^'
+	if not {
+		i++
+	}
+
+	for ; i < len(pattern) && pattern[i] != ']'; i++ {
+		switch {
+		case pattern[i] == '\\' && i+1 < len(pattern):
+			i++
+			ok = ok || pattern[i] == c
+		case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+			lo, hi := pattern[i], pattern[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			ok = ok || (c >= lo && c <= hi)
+			i += 2
+		default:
+			ok = ok || pattern[i] == c
+		}
+	}
+	if i < len(pattern) { // 跳过 ]
+		i++
+	}
+
+	if not {
+		ok = !ok
+	}
+	return i, ok
+}